@@ -0,0 +1,564 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachingSha2Auth(t *testing.T) {
+	// 与go-sql-driver/mysql的测试数据相同
+	scramble := []byte{10, 47, 74, 111, 75, 73, 34, 48, 88, 76, 114, 74, 37, 13, 3, 80, 82, 2, 23, 21}
+	cases := []struct {
+		password string
+		expected string
+	}{
+		{"secret", "f490e76f66d9d86665ce54d98c78d0acfe2fb0b08b423da807144873d30b312c"},
+		{"secret2", "abc3934a012cf342e876071c8ee202de51785b430258a7a0138bc79c4d800bc6"},
+	}
+	for _, c := range cases {
+		if r := hex.EncodeToString(cachingSha2Auth([]byte(c.password), scramble)); r != c.expected {
+			t.Error("password=", c.password, " out=", r, " expected=", c.expected)
+		}
+	}
+	if r := cachingSha2Auth(nil, scramble); len(r) != 0 {
+		t.Errorf("cachingSha2Auth with empty password=% x", r)
+	}
+}
+
+// 用net.Pipe模拟的mysql服务器，测试中按脚本收发packet
+type fakeServer struct {
+	t           *testing.T
+	conn        net.Conn
+	flags       Uint4 // HandshakeV10中的capability flags
+	clientFlags Uint4 // HandshakeResponse41中的capability flags
+}
+
+// 返回模拟的服务器，和连接到它上面的MysqlServer
+func newFakeServer(t *testing.T, config Config) (*fakeServer, *MysqlServer) {
+	clientConn, serverConn := net.Pipe()
+	serverConn.SetDeadline(time.Now().Add(5 * time.Second))
+	config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return clientConn, nil
+	}
+	server := NewMysqlServer(config, nil, testLog{t})
+	if err := server.Connect(); err != nil {
+		t.Fatal("Connect:", err)
+	}
+	return newFakeServerConn(t, serverConn), server
+}
+func newFakeServerConn(t *testing.T, conn net.Conn) *fakeServer {
+	flags := Uint4(CapabilityFlag_CLIENT_PROTOCOL_41 | CapabilityFlag_CLIENT_SECURE_CONNECTION | CapabilityFlag_CLIENT_PLUGIN_AUTH | CapabilityFlag_CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA)
+	return &fakeServer{t, conn, flags, 0}
+}
+func (this *fakeServer) writePacket(seq byte, payload []byte) {
+	length := Uint3(len(payload))
+	if _, err := this.conn.Write(append(append(length.Decode(), seq), payload...)); err != nil {
+		this.t.Fatal("write packet:", err)
+	}
+}
+func (this *fakeServer) readPacket(seq byte) []byte {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(this.conn, header); err != nil {
+		this.t.Fatal("read packet header:", err)
+	}
+	if header[3] != seq {
+		this.t.Fatal("sequence id=", header[3], " expected=", seq)
+	}
+	payload := make([]byte, byte2uint64(header, Uint3Length))
+	if _, err := io.ReadFull(this.conn, payload); err != nil {
+		this.t.Fatal("read packet:", err)
+	}
+	return payload
+}
+
+// HandshakeV10。scramble是20个字节
+func (this *fakeServer) writeHandshake(version string, plugin AuthenticationMethodType, scramble []byte) {
+	flags := this.flags
+	payload := []byte{0x0a}
+	payload = append(payload, version...)
+	payload = append(payload, 0x00)
+	payload = append(payload, 0x01, 0x00, 0x00, 0x00) // connection id
+	payload = append(payload, scramble[:8]...)
+	payload = append(payload, 0x00)
+	payload = append(payload, byte(flags), byte(flags>>8))
+	payload = append(payload, byte(Utf8mb4), 0x02, 0x00)
+	payload = append(payload, byte(flags>>16), byte(flags>>24))
+	payload = append(payload, 21)
+	payload = append(payload, make([]byte, 10)...)
+	payload = append(payload, scramble[8:]...)
+	payload = append(payload, 0x00)
+	payload = append(payload, plugin...)
+	payload = append(payload, 0x00)
+	this.writePacket(0, payload)
+}
+
+// 读HandshakeResponse41，返回auth-response和认证方法
+func (this *fakeServer) readHandshakeResponse(seq byte) ([]byte, AuthenticationMethodType) {
+	payload := this.readPacket(seq)
+	this.clientFlags = Uint4(byte2uint64(payload, 4))
+	// capability flags(4) max packet size(4) charset(1) reserved(23)
+	payload = payload[32:]
+	username := payload[:bytes.IndexByte(payload, 0x00)]
+	payload = payload[len(username)+1:]
+	// CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA，RSA加密后的密码超过250字节
+	length, n := int(payload[0]), 1
+	if payload[0] == 0xfc {
+		length, n = int(payload[1])|int(payload[2])<<8, 3
+	}
+	authResponse := payload[n : n+length]
+	payload = payload[n+length:]
+	plugin := payload[:bytes.IndexByte(payload, 0x00)]
+	return authResponse, AuthenticationMethodType(plugin)
+}
+func (this *fakeServer) writeOK(seq byte) {
+	this.writePacket(seq, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+}
+
+// 只有一行的文本结果集
+func (this *fakeServer) writeResultSet(seq byte, columns []string, values []string) {
+	this.writeResultSetRows(seq, columns, [][]string{values})
+}
+
+// 文本结果集，值都是VAR_STRING。客户端没有CLIENT_DEPRECATE_EOF时，列定义和行之后都是EOF
+// 有CLIENT_DEPRECATE_EOF时，列定义之后没有EOF，行之后是0xFE开头的OK
+func (this *fakeServer) writeResultSetRows(seq byte, columns []string, rows [][]string) {
+	deprecateEOF := CapabilityFlag_CLIENT_DEPRECATE_EOF.isSet(this.clientFlags)
+	this.writePacket(seq, []byte{byte(len(columns))})
+	for _, column := range columns {
+		seq++
+		payload := []byte{3, 'd', 'e', 'f', 0, 0, 0, byte(len(column))}
+		payload = append(payload, column...)
+		payload = append(payload, 0, 0x0c, byte(Utf8mb4), 0x00, 0xff, 0x00, 0x00, 0x00, 0xfd, 0x00, 0x00, 0x00, 0x00, 0x00)
+		this.writePacket(seq, payload)
+	}
+	eof := []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+	if !deprecateEOF {
+		seq++
+		this.writePacket(seq, eof)
+	}
+	for _, values := range rows {
+		row := []byte{}
+		for _, value := range values {
+			row = append(row, byte(len(value)))
+			row = append(row, value...)
+		}
+		seq++
+		this.writePacket(seq, row)
+	}
+	seq++
+	if deprecateEOF {
+		this.writePacket(seq, []byte{0xfe, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+	} else {
+		this.writePacket(seq, eof)
+	}
+}
+
+// 在另一个goroutine里执行Open，返回结果
+func openAsync(server *MysqlServer) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		ret <- server.Open()
+	}()
+	return ret
+}
+func checkOpened(t *testing.T, server *MysqlServer, result chan error) {
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatal("Open:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Open timeout")
+	}
+	if server.state != HANDSHAKED {
+		t.Error("state=", server.state)
+	}
+}
+
+var testScramble = []byte{0x32, 0x47, 0x52, 0x43, 0x3c, 0x41, 0x73, 0x3e, 0x7a, 0x6d, 0x35, 0x5d, 0x58, 0x7e, 0x34, 0x28, 0x66, 0x4d, 0x2c, 0x36}
+
+func TestHandshakeCachingSha2FastAuth(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "secret"})
+	result := openAsync(server)
+
+	fake.writeHandshake("8.0.30", CachingSha2PasswordAuthentication, testScramble)
+	authResponse, plugin := fake.readHandshakeResponse(1)
+	if plugin != CachingSha2PasswordAuthentication || !bytes.Equal(authResponse, cachingSha2Auth([]byte("secret"), testScramble)) {
+		t.Errorf("plugin=%v authResponse=% x", plugin, authResponse)
+	}
+	fake.writePacket(2, []byte{0x01, cachingSha2FastAuthSuccess})
+	fake.writeOK(3)
+	checkOpened(t, server, result)
+}
+
+// 生成测试用的RSA密钥，返回私钥和PEM格式的公钥
+func testRSAKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, _ := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	return privateKey, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+}
+
+// 服务器用私钥解密，与scramble异或后检查密码
+func checkEncryptedPassword(t *testing.T, privateKey *rsa.PrivateKey, encrypted []byte, password string) {
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, privateKey, encrypted, nil)
+	if err != nil {
+		t.Fatal("decrypt:", err)
+	}
+	for i := range plain {
+		plain[i] ^= testScramble[i%len(testScramble)]
+	}
+	if string(plain) != password+"\x00" {
+		t.Errorf("password=%q", plain)
+	}
+}
+
+func TestHandshakeCachingSha2FullAuthRSA(t *testing.T) {
+	privateKey, publicKeyPem := testRSAKey(t)
+
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "secret"})
+	result := openAsync(server)
+
+	fake.writeHandshake("8.0.30", CachingSha2PasswordAuthentication, testScramble)
+	fake.readHandshakeResponse(1)
+	// 缓存中没有这个用户，要求完整认证
+	fake.writePacket(2, []byte{0x01, cachingSha2PerformFullAuth})
+	// 没有TLS，客户端请求公钥
+	if request := fake.readPacket(3); !bytes.Equal(request, []byte{cachingSha2RequestPublicKey}) {
+		t.Fatalf("request public key=% x", request)
+	}
+	fake.writePacket(4, append([]byte{0x01}, publicKeyPem...))
+	checkEncryptedPassword(t, privateKey, fake.readPacket(5), "secret")
+	fake.writeOK(6)
+	checkOpened(t, server, result)
+}
+
+func TestHandshakeAuthSwitch(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "123456"})
+	result := openAsync(server)
+
+	fake.writeHandshake("8.0.30", CachingSha2PasswordAuthentication, make([]byte, 20))
+	fake.readHandshakeResponse(1)
+	// 用户的认证方法是mysql_native_password，服务器要求切换，并发来新的scramble
+	switchRequest := []byte{0xfe}
+	switchRequest = append(switchRequest, SecurePasswordAuthentication...)
+	switchRequest = append(switchRequest, 0x00)
+	switchRequest = append(switchRequest, testScramble...)
+	switchRequest = append(switchRequest, 0x00)
+	fake.writePacket(2, switchRequest)
+	expected := nativeAuth([]byte("123456"), testScramble)
+	if authResponse := fake.readPacket(3); !bytes.Equal(authResponse, expected) {
+		t.Errorf("authResponse=% x, expected=% x", authResponse, expected)
+	}
+	fake.writeOK(4)
+	checkOpened(t, server, result)
+}
+
+func TestHandshakeSha256RequestPublicKey(t *testing.T) {
+	privateKey, publicKeyPem := testRSAKey(t)
+
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "secret"})
+	result := openAsync(server)
+
+	fake.writeHandshake("5.7.40", Sha256PasswordAuthentication, testScramble)
+	authResponse, plugin := fake.readHandshakeResponse(1)
+	if plugin != Sha256PasswordAuthentication || !bytes.Equal(authResponse, []byte{sha256RequestPublicKey}) {
+		t.Fatalf("plugin=%v authResponse=% x", plugin, authResponse)
+	}
+	fake.writePacket(2, append([]byte{0x01}, publicKeyPem...))
+	checkEncryptedPassword(t, privateKey, fake.readPacket(3), "secret")
+	fake.writeOK(4)
+	checkOpened(t, server, result)
+}
+
+func TestHandshakeSha256ServerPubKey(t *testing.T) {
+	privateKey, publicKeyPem := testRSAKey(t)
+	path := filepath.Join(t.TempDir(), "public_key.pem")
+	if err := os.WriteFile(path, publicKeyPem, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "secret", ServerPubKey: path})
+	result := openAsync(server)
+
+	// 配置了公钥，HandshakeResponse中直接是加密后的密码
+	fake.writeHandshake("5.7.40", Sha256PasswordAuthentication, testScramble)
+	authResponse, _ := fake.readHandshakeResponse(1)
+	checkEncryptedPassword(t, privateKey, authResponse, "secret")
+	fake.writeOK(2)
+	checkOpened(t, server, result)
+}
+
+func TestHandshakeSha256EmptyPassword(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl"})
+	result := openAsync(server)
+
+	fake.writeHandshake("5.7.40", Sha256PasswordAuthentication, testScramble)
+	if authResponse, _ := fake.readHandshakeResponse(1); !bytes.Equal(authResponse, []byte{0x00}) {
+		t.Errorf("authResponse=% x", authResponse)
+	}
+	fake.writeOK(2)
+	checkOpened(t, server, result)
+}
+
+func TestHandshakeOldAuthSwitch(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "123456", AllowOldPasswords: true})
+	result := openAsync(server)
+
+	fake.writeHandshake("5.5.62-log", SecurePasswordAuthentication, testScramble)
+	fake.readHandshakeResponse(1)
+	// 用户的密码是old_passwords=1时生成的，服务器要求切换为mysql_old_password
+	fake.writePacket(2, []byte{0xfe})
+	expected := append(scramble323([]byte("123456"), testScramble[:8]), 0x00)
+	if authResponse := fake.readPacket(3); !bytes.Equal(authResponse, expected) {
+		t.Errorf("authResponse=% x, expected=% x", authResponse, expected)
+	}
+	fake.writeOK(4)
+	checkOpened(t, server, result)
+}
+
+func TestHandshakeOldAuthDenied(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl", Pass: "123456"})
+	result := openAsync(server)
+
+	fake.writeHandshake("5.5.62-log", SecurePasswordAuthentication, testScramble)
+	fake.readHandshakeResponse(1)
+	fake.writePacket(2, []byte{0xfe})
+	select {
+	case err := <-result:
+		if e, ok := err.(MysqlError); !ok || e.Code != OLD_PASSWORD_DENIED {
+			t.Error("Open:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Open timeout")
+	}
+}
+
+// 生成测试用的自签名证书，返回证书和信任它的CertPool
+func testCertificate(t *testing.T, dnsName string) (tls.Certificate, *x509.CertPool) {
+	privateKey, _ := testRSAKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: dnsName},
+		DNSNames:              []string{dnsName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey}, pool
+}
+
+// 读SSLRequest，然后在服务器端做TLS握手
+func (this *fakeServer) startTLS(cert tls.Certificate) error {
+	sslRequest := this.readPacket(1)
+	if len(sslRequest) != 32 || !CapabilityFlag_CLIENT_SSL.isSet(Uint4(byte2uint64(sslRequest, 4))) {
+		this.t.Fatalf("SSLRequest=% x", sslRequest)
+	}
+	tlsConn := tls.Server(this.conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	this.conn = tlsConn
+	return nil
+}
+
+func TestHandshakeTLS(t *testing.T) {
+	cert, _ := testCertificate(t, "mysql.example.com")
+	fake, server := newFakeServer(t, Config{Host: "127.0.0.1", User: "repl", Pass: "secret", TLSMode: TLSRequired})
+	fake.flags |= Uint4(CapabilityFlag_CLIENT_SSL)
+	result := openAsync(server)
+
+	fake.writeHandshake("8.0.30", CachingSha2PasswordAuthentication, testScramble)
+	if err := fake.startTLS(cert); err != nil {
+		t.Fatal("tls handshake:", err)
+	}
+	// SSLRequest之后，sequence id继续增加
+	fake.readHandshakeResponse(2)
+	fake.writePacket(3, []byte{0x01, cachingSha2PerformFullAuth})
+	// 在加密的连接上直接发送明文密码
+	if password := fake.readPacket(4); string(password) != "secret\x00" {
+		t.Errorf("password=%q", password)
+	}
+	fake.writeOK(5)
+	checkOpened(t, server, result)
+	if !server.isSecure() {
+		t.Error("connection is not encrypted")
+	}
+}
+
+func TestHandshakeTLSVerify(t *testing.T) {
+	cert, pool := testCertificate(t, "mysql.example.com")
+	cases := []struct {
+		host string
+		mode TLSModeType
+		ok   bool
+	}{
+		{"mysql.example.com", TLSVerifyIdentity, true},
+		{"127.0.0.1", TLSVerifyIdentity, false}, // 主机名不匹配
+		{"127.0.0.1", TLSVerifyCA, true},        // 不校验主机名
+	}
+	for _, c := range cases {
+		fake, server := newFakeServer(t, Config{Host: c.host, User: "repl", TLSMode: c.mode, TLSConfig: &tls.Config{RootCAs: pool}})
+		fake.flags |= Uint4(CapabilityFlag_CLIENT_SSL)
+		result := openAsync(server)
+
+		fake.writeHandshake("8.0.30", SecurePasswordAuthentication, testScramble)
+		if err := fake.startTLS(cert); err != nil {
+			if c.ok {
+				t.Error("host=", c.host, " tls handshake:", err)
+			} else if err := <-result; err == nil {
+				t.Error("host=", c.host, " certificate should not be accepted")
+			}
+			continue
+		}
+		if !c.ok {
+			t.Error("host=", c.host, " certificate should not be accepted")
+			continue
+		}
+		fake.readHandshakeResponse(2)
+		fake.writeOK(3)
+		checkOpened(t, server, result)
+	}
+}
+
+func TestHandshakeTLSNotSupported(t *testing.T) {
+	fake, server := newFakeServer(t, Config{Host: "127.0.0.1", Port: "3306", User: "repl", TLSMode: TLSRequired})
+	result := openAsync(server)
+
+	fake.writeHandshake("8.0.30", SecurePasswordAuthentication, testScramble)
+	select {
+	case err := <-result:
+		if _, ok := err.(TLSNotSupportedError); !ok {
+			t.Error("Open:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Open timeout")
+	}
+}
+
+func TestHandshakeTLSPreferred(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl", TLSMode: TLSPreferred})
+	result := openAsync(server)
+
+	// 服务器不支持SSL时不加密
+	fake.writeHandshake("8.0.30", SecurePasswordAuthentication, testScramble)
+	fake.readHandshakeResponse(1)
+	fake.writeOK(2)
+	checkOpened(t, server, result)
+	if server.isSecure() {
+		t.Error("connection should not be encrypted")
+	}
+}
+
+func TestHandshakeCompression(t *testing.T) {
+	cases := []struct {
+		version     string
+		flags       Uint4
+		compression CompressionType
+		expected    CompressionType
+	}{
+		{"8.0.30", Uint4(CapabilityFlag_CLIENT_COMPRESS | CapabilityFlag_CLIENT_ZSTD_COMPRESSION_ALGORITHM), CompressionZstd, CompressionZstd},
+		{"8.0.30", Uint4(CapabilityFlag_CLIENT_COMPRESS | CapabilityFlag_CLIENT_ZSTD_COMPRESSION_ALGORITHM), CompressionZlib, CompressionZlib},
+		{"5.7.40", Uint4(CapabilityFlag_CLIENT_COMPRESS), CompressionZstd, CompressionZlib}, // 不支持zstd时使用zlib
+		{"8.0.30", 0, CompressionZlib, CompressionNone},
+	}
+	for _, c := range cases {
+		fake, server := newFakeServer(t, Config{User: "repl", Compression: c.compression})
+		fake.flags |= c.flags
+		result := openAsync(server)
+
+		fake.writeHandshake(c.version, SecurePasswordAuthentication, testScramble)
+		fake.readHandshakeResponse(1)
+		if CapabilityFlag_CLIENT_COMPRESS.isSet(fake.clientFlags) != (c.expected == CompressionZlib) ||
+			CapabilityFlag_CLIENT_ZSTD_COMPRESSION_ALGORITHM.isSet(fake.clientFlags) != (c.expected == CompressionZstd) {
+			t.Errorf("version=%v compression=%v client flags=%x", c.version, c.compression, fake.clientFlags)
+		}
+		fake.writeOK(2)
+		checkOpened(t, server, result)
+		if server.compression != c.expected {
+			t.Errorf("version=%v compression=%v, negotiated %v", c.version, c.compression, server.compression)
+		}
+		if c.expected == CompressionNone {
+			continue
+		}
+
+		// 之后的命令都是压缩的
+		compressed, _ := newCompressedConn(fake.conn, c.expected)
+		fake.conn = compressed
+		queryResult := make(chan error, 1)
+		go func() {
+			_, err := server.query("SELECT @@version")
+			queryResult <- err
+		}()
+		if payload := fake.readPacket(0); string(payload) != "\x03SELECT @@version" {
+			t.Errorf("COM_QUERY=%q", payload)
+		}
+		fake.writePacket(1, append([]byte{0xff, 0x48, 0x04, '#', 'H', 'Y', '0', '0', '0'}, "No tables used"...))
+		if err := <-queryResult; err == nil || !strings.Contains(err.Error(), "No tables used") {
+			t.Error("query:", err)
+		}
+	}
+}
+
+// MySQL 8.0的服务器设置了几乎所有的标志位，客户端只回应自己支持的
+func TestHandshakeCapabilityFlags(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl"})
+	fake.flags = 0xdfffffff
+	result := openAsync(server)
+	fake.writeHandshake("8.0.30", SecurePasswordAuthentication, testScramble)
+	fake.readHandshakeResponse(1)
+	if fake.clientFlags != Uint4(clientCapabilityFlags) {
+		t.Errorf("client flags=%x expected=%x", fake.clientFlags, clientCapabilityFlags)
+	}
+	for _, flag := range []CapalibilityFlagType{CapabilityFlag_CLIENT_SSL, CapabilityFlag_CLIENT_COMPRESS, CapabilityFlag_CLIENT_SESSION_TRACK, CapabilityFlag_CLIENT_OPTIONAL_RESULTSET_METADATA, CapabilityFlag_CLIENT_MULTI_STATEMENTS} {
+		if flag.isSet(fake.clientFlags) {
+			t.Errorf("client flags=%x, %s is set", fake.clientFlags, capabilityFlagDesc[flag])
+		}
+	}
+	fake.writeOK(2)
+	checkOpened(t, server, result)
+
+	// 双方都支持CLIENT_DEPRECATE_EOF，结果集以OK结束
+	queryResult := make(chan ResultSetType, 1)
+	go func() {
+		resultSet, err := server.query("SELECT @@version")
+		if err != nil {
+			t.Error("query:", err)
+		}
+		queryResult <- resultSet
+	}()
+	fake.readPacket(0)
+	fake.writeResultSetRows(1, []string{"@@version"}, [][]string{{"8.0.30"}, {"8.0.31"}})
+	resultSet := <-queryResult
+	if value, _ := resultSet.GetString(1, "@@version"); len(resultSet.Rows) != 2 || value != "8.0.31" {
+		t.Error("resultSet=", resultSet)
+	}
+}
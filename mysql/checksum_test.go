@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"testing"
+)
+
+func TestChecksumMismatch(t *testing.T) {
+	pkt := testEventPacketAt(EventTypeXidEvent, 200, []byte{0x17, 0, 0, 0, 0, 0, 0, 0})
+	if event := readTestEvent(t, pkt); event.(XIDEventType).Xid != 23 {
+		t.Error("xid=", event)
+	}
+
+	// 改掉body中的一个字节
+	pkt[24] ^= 0xff
+	_, err := readTestEventWith(t, pkt, NewConfig())
+	mismatch, ok := err.(ChecksumMismatchError)
+	if !ok {
+		t.Fatal("err=", err)
+	}
+	// event长度=19+8+4，开始的位置=LogPos-长度
+	if mismatch.Filename != "mysql-bin.000001" || mismatch.BinlogPos != 169 || mismatch.Expected == mismatch.Actual {
+		t.Error("mismatch=", mismatch)
+	}
+
+	// 不校验时只去掉CRC
+	config := NewConfig()
+	config.DisableChecksum = true
+	if _, err := readTestEventWith(t, pkt, config); err != nil {
+		t.Error("DisableChecksum:", err)
+	}
+}
+
+func testFormatDescriptionEvent(version string, alg Uint1) []byte {
+	body := []byte{0x04, 0x00}
+	body = append(body, version...)
+	body = append(body, make([]byte, 50-len(version))...)
+	body = append(body, 0, 0, 0, 0, 19)
+	body = append(body, 56, 13, 0) // 各event的post-header长度
+	body = append(body, byte(alg))
+	return testEventPacket(EventTypeFormatDescriptionEvent, body)
+}
+
+func TestFormatDescriptionChecksumAlg(t *testing.T) {
+	pkt := testFormatDescriptionEvent("8.0.30", BINLOG_CHECKSUM_ALG_CRC32)
+	// CRC是在设置IN_USE标志之前计算的
+	pkt[22] |= byte(LOG_EVENT_BINLOG_IN_USE_F)
+	fde := readTestEvent(t, pkt).(FormatDescriptionEventType)
+	if fde.ChecksumAlg != BINLOG_CHECKSUM_ALG_CRC32 || len(fde.EventTypeHeaderLength) != 3 {
+		t.Error("fde=", fde)
+	}
+
+	// 校验算法是OFF时，末尾仍然有CRC的位置，但不校验
+	pkt = testFormatDescriptionEvent("8.0.30", BINLOG_CHECKSUM_ALG_OFF)
+	copy(pkt[len(pkt)-4:], []byte{0, 0, 0, 0})
+	fde = readTestEvent(t, pkt).(FormatDescriptionEventType)
+	if fde.ChecksumAlg != BINLOG_CHECKSUM_ALG_OFF || len(fde.EventTypeHeaderLength) != 3 {
+		t.Error("fde=", fde)
+	}
+}
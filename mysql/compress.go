@@ -0,0 +1,186 @@
+package mysql
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressedHeaderLength      = 7          // 压缩packet的头部长度
+	minCompressLength           = 50         // 小于这个长度的payload不压缩，与libmysql相同
+	maxCompressedPayloadLength  = 0x00FFFFFF // 压缩packet中长度只有3个字节
+	defaultZstdCompressionLevel = 3
+)
+
+// 压缩协议的连接。握手成功后包装MysqlStream.conn，对Stream来说读写的仍然是普通的packet
+// 每个压缩packet：3字节压缩后的长度，1字节sequence id，3字节压缩前的长度(为0表示payload没有压缩)，然后是payload
+// 参考 https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_compression.html
+type compressedConn struct {
+	net.Conn
+	compression CompressionType
+	readBuf     []byte // 已经解压、还没有被读走的数据
+	sequenceId  Uint1  // 下一个压缩packet的sequence id，与普通packet的sequence id分开计数
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	mutex       sync.Mutex // Close可能与读写网络的goroutine中的压缩、解压同时进行
+}
+
+func newCompressedConn(conn net.Conn, compression CompressionType) (*compressedConn, error) {
+	ret := &compressedConn{}
+	ret.Conn = conn
+	ret.compression = compression
+	if compression == CompressionZstd {
+		var err error
+		if ret.zstdEncoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(defaultZstdCompressionLevel))); err != nil {
+			return nil, err
+		}
+		if ret.zstdDecoder, err = zstd.NewReader(nil); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// 读出解压后的数据。缓存为空时从连接读一个压缩packet
+func (this *compressedConn) Read(b []byte) (int, error) {
+	for len(this.readBuf) == 0 {
+		if err := this.readCompressedPacket(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, this.readBuf)
+	this.readBuf = this.readBuf[n:]
+	return n, nil
+}
+func (this *compressedConn) readCompressedPacket() error {
+	header := make([]byte, compressedHeaderLength)
+	if _, err := io.ReadFull(this.Conn, header); err != nil {
+		return err
+	}
+	compressedLength := byte2uint64(header[0:3], Uint3Length)
+	this.sequenceId = Uint1(header[3]) + 1
+	uncompressedLength := byte2uint64(header[4:7], Uint3Length)
+
+	payload := make([]byte, compressedLength)
+	if _, err := io.ReadFull(this.Conn, payload); err != nil {
+		return err
+	}
+	if uncompressedLength == 0 {
+		// 没有压缩
+		this.readBuf = payload
+		return nil
+	}
+	buf, err := this.uncompress(payload, int(uncompressedLength))
+	if err != nil {
+		return err
+	}
+	if len(buf) != int(uncompressedLength) {
+		return Error{fmt.Sprintf("Compressed packet uncompressed length=%d, expected %d", len(buf), uncompressedLength), 0}
+	}
+	this.readBuf = buf
+	return nil
+}
+
+// 把普通的packet压缩后写出。b是Stream.Write写出的一个完整packet
+func (this *compressedConn) Write(b []byte) (int, error) {
+	// 普通packet的sequence id为0，表示是一个新的命令，压缩packet的sequence id也从0开始
+	if len(b) > 3 && b[3] == 0 {
+		this.sequenceId = 0
+	}
+	written := 0
+	for written < len(b) {
+		n := len(b) - written
+		if n > maxCompressedPayloadLength {
+			n = maxCompressedPayloadLength
+		}
+		if err := this.writeCompressedPacket(b[written : written+n]); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+func (this *compressedConn) writeCompressedPacket(b []byte) error {
+	payload := b
+	uncompressedLength := Uint3(0)
+	if len(b) >= minCompressLength {
+		compressed, err := this.compress(b)
+		if err != nil {
+			return err
+		}
+		// 压缩后反而更长时，发送原来的数据
+		if len(compressed) < len(b) {
+			payload = compressed
+			uncompressedLength = Uint3(len(b))
+		}
+	}
+	compressedLength := Uint3(len(payload))
+	buf := make([]byte, 0, compressedHeaderLength+len(payload))
+	buf = append(buf, compressedLength.Decode()...)
+	buf = append(buf, byte(this.sequenceId))
+	buf = append(buf, uncompressedLength.Decode()...)
+	buf = append(buf, payload...)
+	this.sequenceId++
+	_, err := this.Conn.Write(buf)
+	return err
+}
+func (this *compressedConn) compress(b []byte) ([]byte, error) {
+	if this.compression == CompressionZstd {
+		this.mutex.Lock()
+		defer this.mutex.Unlock()
+		if this.zstdEncoder == nil {
+			return nil, net.ErrClosed
+		}
+		return this.zstdEncoder.EncodeAll(b, nil), nil
+	}
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+func (this *compressedConn) uncompress(b []byte, uncompressedLength int) ([]byte, error) {
+	if this.compression == CompressionZstd {
+		this.mutex.Lock()
+		defer this.mutex.Unlock()
+		if this.zstdDecoder == nil {
+			return nil, net.ErrClosed
+		}
+		return this.zstdDecoder.DecodeAll(b, make([]byte, 0, uncompressedLength))
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	buf := bytes.NewBuffer(make([]byte, 0, uncompressedLength))
+	if _, err = io.Copy(buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 结束zstd压缩、解压使用的goroutine，再关闭原来的连接
+func (this *compressedConn) Close() error {
+	this.mutex.Lock()
+	if this.zstdDecoder != nil {
+		this.zstdDecoder.Close()
+		this.zstdDecoder = nil
+	}
+	if this.zstdEncoder != nil {
+		this.zstdEncoder.Close()
+		this.zstdEncoder = nil
+	}
+	this.mutex.Unlock()
+	return this.Conn.Close()
+}
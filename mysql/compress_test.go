@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 一端压缩写，另一端解压读
+func testCompressedPipe(t *testing.T, compression CompressionType) (*compressedConn, *compressedConn) {
+	c1, c2 := net.Pipe()
+	c1.SetDeadline(time.Now().Add(5 * time.Second))
+	c2.SetDeadline(time.Now().Add(5 * time.Second))
+	client, err := newCompressedConn(c1, compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := newCompressedConn(c2, compression)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+// 普通packet：3字节长度，1字节sequence id，然后是payload
+func testPlainPacket(seq byte, payload []byte) []byte {
+	length := Uint3(len(payload))
+	return append(append(length.Decode(), seq), payload...)
+}
+
+func TestCompressedConn(t *testing.T) {
+	for _, compression := range []CompressionType{CompressionZlib, CompressionZstd} {
+		client, server := testCompressedPipe(t, compression)
+		for _, size := range []int{1, minCompressLength, 1000, 100 * 1024} {
+			packet := testPlainPacket(0, bytes.Repeat([]byte("binlog"), size)[:size])
+			go client.Write(packet)
+			buf := make([]byte, len(packet))
+			if _, err := io.ReadFull(server, buf); err != nil {
+				t.Fatal("compression=", compression, " size=", size, " read:", err)
+			}
+			if !bytes.Equal(buf, packet) {
+				t.Error("compression=", compression, " size=", size, " data mismatch")
+			}
+		}
+	}
+}
+
+func TestCompressedConnFraming(t *testing.T) {
+	c1, c2 := net.Pipe()
+	c2.SetDeadline(time.Now().Add(5 * time.Second))
+	client, _ := newCompressedConn(c1, CompressionZlib)
+
+	// 小于minCompressLength时不压缩，压缩前的长度为0
+	packet := testPlainPacket(0, []byte{0x0e}) // COM_PING
+	go client.Write(packet)
+	buf := make([]byte, compressedHeaderLength+len(packet))
+	if _, err := io.ReadFull(c2, buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := append([]byte{0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, packet...)
+	if !bytes.Equal(buf, expected) {
+		t.Errorf("compressed packet=% x, expected=% x", buf, expected)
+	}
+
+	// 同一个命令中，压缩packet的sequence id继续增加
+	packet = testPlainPacket(1, bytes.Repeat([]byte{'a'}, 100))
+	go client.Write(packet)
+	header := make([]byte, compressedHeaderLength)
+	if _, err := io.ReadFull(c2, header); err != nil {
+		t.Fatal(err)
+	}
+	compressedLength := byte2uint64(header[0:3], Uint3Length)
+	if header[3] != 1 || byte2uint64(header[4:7], Uint3Length) != uint64(len(packet)) || compressedLength >= uint64(len(packet)) {
+		t.Errorf("compressed header=% x", header)
+	}
+	io.ReadFull(c2, make([]byte, compressedLength))
+
+	// 新的命令从0开始
+	go client.Write(testPlainPacket(0, []byte{0x0e}))
+	if _, err := io.ReadFull(c2, buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf[3] != 0 {
+		t.Errorf("compressed sequence id=%d", buf[3])
+	}
+}
+
+func TestCompressedConnClose(t *testing.T) {
+	client, server := testCompressedPipe(t, CompressionZstd)
+	packet := testPlainPacket(0, bytes.Repeat([]byte("binlog"), 1000))
+	go client.Write(packet)
+	if _, err := io.ReadFull(server, make([]byte, len(packet))); err != nil {
+		t.Fatal(err)
+	}
+	// 释放zstd的资源，同时关闭原来的连接
+	if err := client.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	if client.zstdEncoder != nil || client.zstdDecoder != nil {
+		t.Error("zstd encoder/decoder not closed")
+	}
+	if _, err := server.Read(make([]byte, 1)); err == nil {
+		t.Error("connection is not closed")
+	}
+	if _, err := client.compress(packet); err == nil {
+		t.Error("compress after Close")
+	}
+	server.Close()
+}
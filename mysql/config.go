@@ -1,5 +1,12 @@
 package mysql
 
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
 //import "fmt"
 
 type DumpFromFlag int
@@ -8,23 +15,65 @@ const (
 	DumpFromBeginning DumpFromFlag = 1 // 启动时从binlog的最开始开始
 	DumpFromPosition               = 2 // 启动时从某特定位置开始读
 	DumpFromLatest                 = 3 // 启动时从当前位置开始读
+	DumpFromGTID                   = 4 // 启动时从Config.GTIDSet之后开始读，需要服务器打开gtid_mode
+)
+
+// 是否使用TLS加密连接，以及如何校验服务器的证书
+type TLSModeType int
+
+const (
+	TLSDisabled       TLSModeType = iota // 不使用TLS。设置了Config.TLSConfig时按TLSVerifyIdentity处理
+	TLSPreferred                         // 服务器支持SSL时使用TLS，否则不加密。不校验证书
+	TLSRequired                          // 必须使用TLS，不校验证书
+	TLSVerifyCA                          // 必须使用TLS，校验证书是否由可信的CA签发，不校验主机名
+	TLSVerifyIdentity                    // 必须使用TLS，校验证书和主机名
 )
 
+// 协议压缩算法
+type CompressionType int
+
+const (
+	CompressionNone CompressionType = iota // 不压缩
+	CompressionZlib                        // CLIENT_COMPRESS，zlib
+	CompressionZstd                        // CLIENT_ZSTD_COMPRESSION_ALGORITHM，8.0.18开始支持。服务器不支持时使用zlib
+)
+
+// 建立连接的函数，与net.Dialer.DialContext相同。network是tcp或unix
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
 // binlog文件的位置
 type BinglogType struct {
 	Filename  string
 	BinlogPos uint32
 }
 type Config struct {
-	Host           string
-	Port           string
-	User           string
-	Pass           string
-	ServerId       int
-	DumpFrom       DumpFromFlag
-	BinlogPosition BinglogType
-	Continue       bool // 启动时是否从上次记录的位置开始。如果为true，并且有记录，则DumpFrom无效；否则以DumpFrom为准
-	LogTag         uint32
+	Host              string
+	Port              string
+	Socket            string          // unix socket的路径，如/var/run/mysqld/mysqld.sock。设置后不使用Host和Port
+	DialTimeout       time.Duration   // 连接的超时，也用于TLS握手。0时不限制
+	ReadTimeout       time.Duration   // 每次读网络的超时，0时不限制。服务器空闲时可能很久没有新的event，复制时一般不设置
+	WriteTimeout      time.Duration   // 每次写网络的超时，0时不限制
+	DialContext       DialContextFunc // 自定义的连接方法，如通过SSH隧道。为nil时使用net.Dialer
+	User              string
+	Pass              string
+	ServerId          int
+	DumpFrom          DumpFromFlag
+	BinlogPosition    BinglogType
+	GTIDSet           string // DumpFromGTID时已经执行过的GTID集合，如 3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5
+	Continue          bool   // 启动时是否从上次记录的位置开始。如果为true，并且有记录，则DumpFrom无效；否则以DumpFrom为准
+	ServerPubKey      string // 服务器RSA公钥(PEM)文件的路径，用于sha256_password/caching_sha2_password。为空时向服务器请求
+	AllowOldPasswords bool   // 是否允许使用不安全的mysql_old_password(4.1之前的323 scramble)认证
+	TLSMode           TLSModeType
+	TLSConfig         *tls.Config     // TLS的配置，如RootCAs、客户端证书等。为nil时使用系统的CA
+	Compression       CompressionType // 压缩复制流，跨机房时可以节省带宽。服务器不支持时不压缩
+	DisableChecksum   bool            // 不校验event的CRC32，向服务器声明checksum为NONE
+	Reconnect         bool            // 复制时网络断开是否自动重连，重连后从最后提交的事务之后继续
+	ReconnectBackoff  time.Duration   // 第一次重连前的等待时间，之后每次翻倍。0时为1秒
+	MaxReconnectWait  time.Duration   // 重连等待时间的上限，0时为1分钟
+	MaxReconnects     int             // 连续重连失败多少次后放弃，0时不限制
+	LoadSchema        bool            // 遇到DDL中没有出现过的表时查询它的结构，否则这些表的列名为空，无符号数按有符号读
+	SchemaLoader      SchemaLoader    // LoadSchema时用来查询表结构。为nil时另开一个连接查询information_schema
+	LogTag            uint32
 	//buf []byte
 }
 
@@ -40,9 +89,24 @@ func NewConfig() *Config {
 	return ret
 }
 
+// 断点：已经处理完的binlog位置，以及当时的表结构。用于重启后继续复制
+type Checkpoint struct {
+	Binlog  BinglogType // 下一个要读的event的位置
+	GTIDSet string      // 已经执行过的GTID集合，不使用GTID时为空
+	Columns SchemaAttr  // 库名=>表名=>列的属性
+}
+
 // 保存当前服务器状态的接口
+// 只在事务边界(COMMIT/XID、事务外的DDL、rotate)调用Save，所以Load读出的总是一个完整事务之后的位置
 type Storage interface {
-	Save()
+	Load() (*Checkpoint, error) // 还没有保存过时返回nil, nil
+	Save(checkpoint Checkpoint) error
+}
+
+// 查询表结构的接口。复制中遇到结构未知的表时调用，结果缓存在ServerConfigType.Columns中，表被ALTER后重新查询
+type SchemaLoader interface {
+	// 按列的顺序返回表的各列。表不存在时返回nil, nil
+	LoadColumns(schema, table string) (TableAttr, error)
 }
 
 // 输出log用的
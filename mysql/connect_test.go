@@ -0,0 +1,619 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestConnectUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysqld.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix socket is not supported:", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+
+	server := NewMysqlServer(Config{Host: "127.0.0.1", Port: "1", Socket: path}, nil, testLog{t})
+	if err := server.Connect(); err != nil {
+		t.Fatal("Connect:", err)
+	}
+	select {
+	case conn := <-accepted:
+		conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("not connected to the unix socket")
+	}
+	if server.state != CONNECTED {
+		t.Error("state=", server.state)
+	}
+}
+
+func TestConnectDialContext(t *testing.T) {
+	var network, address string
+	config := Config{Host: "::1", Port: "3306"}
+	config.DialContext = func(ctx context.Context, n, a string) (net.Conn, error) {
+		network, address = n, a
+		conn, _ := net.Pipe()
+		return conn, nil
+	}
+	server := NewMysqlServer(config, nil, testLog{t})
+	if err := server.Connect(); err != nil {
+		t.Fatal("Connect:", err)
+	}
+	if network != "tcp" || address != "[::1]:3306" {
+		t.Errorf("network=%v address=%v", network, address)
+	}
+}
+
+func TestConnectDialTimeout(t *testing.T) {
+	config := Config{Host: "127.0.0.1", Port: "3306", DialTimeout: 50 * time.Millisecond}
+	config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		// 模拟连不上的服务器
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	server := NewMysqlServer(config, nil, testLog{t})
+	start := time.Now()
+	err := server.Connect()
+	if e, ok := err.(MysqlError); !ok || e.Code != CONNECTING_FAILED {
+		t.Error("Connect:", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Error("Connect timeout after", elapsed)
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl", ReadTimeout: 50 * time.Millisecond})
+	defer fake.conn.Close()
+	// 服务器不发送HandshakeV10
+	select {
+	case err := <-openAsync(server):
+		if e, ok := err.(net.Error); !ok || !e.Timeout() {
+			t.Error("Open:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ReadTimeout does not work")
+	}
+}
+
+func TestNoReadTimeout(t *testing.T) {
+	fake, server := newFakeServer(t, Config{User: "repl"})
+	result := openAsync(server)
+	// 不设置ReadTimeout时，服务器没有数据的时间远超过上面的50ms也不会超时
+	time.Sleep(500 * time.Millisecond)
+	fake.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fake.writeHandshake("8.0.30", SecurePasswordAuthentication, testScramble)
+	fake.readHandshakeResponse(1)
+	fake.writeOK(2)
+	checkOpened(t, server, result)
+}
+
+// 什么都不做的回调
+type testCallback struct{}
+
+func (this testCallback) OnQuery(sql string)                            {}
+func (this testCallback) OnInsert(row DataHistory)                      {}
+func (this testCallback) OnUpdate(row DataHistory)                      {}
+func (this testCallback) OnDelete(row DataHistory)                      {}
+func (this testCallback) OnEnd() bool                                   { return false }
+func (this testCallback) OnColumnAttr(schema, table string, colIdx int) {}
+
+// 完成握手的模拟服务器
+func openFakeServer(t *testing.T, config Config, version string) (*fakeServer, *MysqlServer) {
+	fake, server := newFakeServer(t, config)
+	result := openAsync(server)
+	fake.acceptHandshake(version)
+	checkOpened(t, server, result)
+	return fake, server
+}
+
+// 用native password完成握手
+func (this *fakeServer) acceptHandshake(version string) {
+	this.writeHandshake(version, SecurePasswordAuthentication, testScramble)
+	this.readHandshakeResponse(1)
+	this.writeOK(2)
+}
+
+// 等待result返回ctx.Err()
+func checkCanceled(t *testing.T, name string, result chan error) {
+	select {
+	case err := <-result:
+		if err != context.Canceled {
+			t.Error(name, ":", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal(name, " is not canceled")
+	}
+}
+
+func TestConnectContext(t *testing.T) {
+	config := Config{Host: "127.0.0.1", Port: "3306"}
+	config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	server := NewMysqlServer(config, nil, testLog{t})
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- server.ConnectContext(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	checkCanceled(t, "ConnectContext", result)
+}
+
+func TestOpenContext(t *testing.T) {
+	_, server := newFakeServer(t, Config{User: "repl"})
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- server.OpenContext(ctx)
+	}()
+	// 服务器不发送HandshakeV10，Open一直阻塞
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	checkCanceled(t, "OpenContext", result)
+	if server.state != UNCONNECTED {
+		t.Error("state=", server.state)
+	}
+}
+
+func TestReplicateContext(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	fake, server := openFakeServer(t, config, "5.5.62-log")
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- server.ReplicateContext(ctx, testCallback{})
+	}()
+	if com := fake.readPacket(0); com[0] != 0x15 {
+		t.Fatalf("COM_REGISTER_SLAVE=% x", com)
+	}
+	fake.writeOK(1)
+	if com := fake.readPacket(0); com[0] != 0x12 {
+		t.Fatalf("COM_BINLOG_DUMP=% x", com)
+	}
+	// 没有新的event，Replicate一直阻塞
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	checkCanceled(t, "ReplicateContext", result)
+	if server.state != UNCONNECTED {
+		t.Error("state=", server.state)
+	}
+}
+
+// 在另一个goroutine里Close，模拟的服务器应该收到COM_QUIT
+func closeFakeServer(t *testing.T, fake *fakeServer, server *MysqlServer) {
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Close()
+	}()
+	if quit := fake.readPacket(0); !bytes.Equal(quit, []byte{0x01}) {
+		t.Errorf("COM_QUIT=% x", quit)
+	}
+	if err := <-result; err != nil {
+		t.Error("Close:", err)
+	}
+	// 客户端已经关闭了连接
+	if _, err := fake.conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection is not closed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	fake, server := openFakeServer(t, Config{User: "repl"}, "8.0.30")
+	closeFakeServer(t, fake, server)
+	if server.state != UNCONNECTED {
+		t.Error("state=", server.state)
+	}
+	// 可以多次调用
+	if err := server.Close(); err != nil {
+		t.Error("Close again:", err)
+	}
+	if _, err := server.query("SELECT 1"); err == nil {
+		t.Error("query after Close")
+	}
+}
+
+func TestCloseGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		fake, server := openFakeServer(t, Config{User: "repl"}, "8.0.30")
+		// 服务器在payload的中间断开，ReadPayload提前返回
+		result := make(chan error, 1)
+		go func() {
+			_, err := server.query("SELECT 1")
+			result <- err
+		}()
+		fake.readPacket(0)
+		fake.conn.Write([]byte{0x20, 0x00, 0x00, 0x01, 0x00, 0x00})
+		fake.conn.Close()
+		if err := <-result; err == nil {
+			t.Error("query should fail")
+		}
+		if err := server.Close(); err != nil {
+			t.Error("Close:", err)
+		}
+
+		// 正常关闭
+		fake, server = openFakeServer(t, Config{User: "repl"}, "8.0.30")
+		closeFakeServer(t, fake, server)
+	}
+	// goroutine结束需要一点时间
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+	}
+	buf := make([]byte, 1<<16)
+	t.Errorf("goroutines before=%d after=%d\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+}
+
+type reconnectCallback struct {
+	testCallback
+	attempts chan int
+}
+
+func (this reconnectCallback) OnReconnect(attempt int, err error) {
+	this.attempts <- attempt
+}
+
+// 模拟服务器的event，LogPos是下一个event的位置
+func testEventPacketAt(eventType Uint1, logPos uint32, body []byte) []byte {
+	pkt := testEventPacket(eventType, body)
+	binary.LittleEndian.PutUint32(pkt[18:22], logPos)
+	binary.LittleEndian.PutUint32(pkt[len(pkt)-4:], crc32.ChecksumIEEE(pkt[5:len(pkt)-4]))
+	return pkt
+}
+
+// 回应Replicate开始时设置checksum的语句、COM_REGISTER_SLAVE，返回COM_BINLOG_DUMP中的位置
+func (this *fakeServer) acceptDump() BinglogType {
+	if com := this.readPacket(0); string(com) != "\x03SET @master_binlog_checksum=@@global.binlog_checksum" {
+		this.t.Fatalf("COM_QUERY=%q", com)
+	}
+	this.writeOK(1)
+	if com := this.readPacket(0); string(com) != "\x03SELECT @master_binlog_checksum" {
+		this.t.Fatalf("COM_QUERY=%q", com)
+	}
+	this.writeResultSet(1, []string{"@master_binlog_checksum"}, []string{"CRC32"})
+	if com := this.readPacket(0); com[0] != 0x15 {
+		this.t.Fatalf("COM_REGISTER_SLAVE=% x", com)
+	}
+	this.writeOK(1)
+	com := this.readPacket(0)
+	if com[0] != 0x12 {
+		this.t.Fatalf("COM_BINLOG_DUMP=% x", com)
+	}
+	return BinglogType{string(com[11:]), binary.LittleEndian.Uint32(com[1:5])}
+}
+
+func TestReconnect(t *testing.T) {
+	conns := make(chan net.Conn, 2)
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	config.Reconnect = true
+	config.ReconnectBackoff = time.Millisecond
+	config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		serverConn.SetDeadline(time.Now().Add(5 * time.Second))
+		conns <- serverConn
+		return clientConn, nil
+	}
+	server := NewMysqlServer(config, nil, testLog{t})
+	if err := server.Connect(); err != nil {
+		t.Fatal("Connect:", err)
+	}
+	fake := newFakeServerConn(t, <-conns)
+	result := openAsync(server)
+	fake.acceptHandshake("8.0.30")
+	checkOpened(t, server, result)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callback := reconnectCallback{attempts: make(chan int, 1)}
+	go func() {
+		result <- server.ReplicateContext(ctx, callback)
+	}()
+	if binlog := fake.acceptDump(); binlog != (BinglogType{"mysql-bin.000001", 4}) {
+		t.Fatal("dump from", binlog)
+	}
+	// 切换到000002后事务开始，在事务中间断开
+	rotate := append([]byte{4, 0, 0, 0, 0, 0, 0, 0}, "mysql-bin.000002"...)
+	fake.conn.Write(testEventPacketAt(EventTypeRotateEvent, 500, rotate))
+	begin := append([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "BEGIN"...)
+	fake.conn.Write(testEventPacketAt(EventTypeQueryEvent, 300, begin))
+	fake.conn.Close()
+
+	select {
+	case attempt := <-callback.attempts:
+		if attempt != 1 {
+			t.Error("attempt=", attempt)
+		}
+	case err := <-result:
+		t.Fatal("Replicate:", err)
+	}
+	fake = newFakeServerConn(t, <-conns)
+	fake.acceptHandshake("8.0.30")
+	// 从最后提交的位置继续，而不是事务中间
+	if binlog := fake.acceptDump(); binlog != (BinglogType{"mysql-bin.000002", 4}) {
+		t.Error("resume from", binlog)
+	}
+	cancel()
+	checkCanceled(t, "ReplicateContext", result)
+}
+
+func TestReconnectAfterClose(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	config.Reconnect = true
+	config.ReconnectBackoff = time.Millisecond
+	fake, server := openFakeServer(t, config, "8.0.30")
+	callback := reconnectCallback{attempts: make(chan int, 1)}
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(callback)
+	}()
+	fake.acceptDump()
+	// 复制中被Close，只关闭socket，返回nil，不重连
+	if err := server.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	if _, err := fake.conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection is not closed")
+	}
+	select {
+	case attempt := <-callback.attempts:
+		t.Fatal("reconnect attempt=", attempt)
+	case err := <-result:
+		if err != nil {
+			t.Error("Replicate:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Replicate not returned")
+	}
+}
+
+// 等待重连时被Close，不等到重连的时间
+func TestCloseWhileReconnecting(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	config.Reconnect = true
+	config.ReconnectBackoff = time.Hour
+	config.MaxReconnectWait = time.Hour
+	fake, server := openFakeServer(t, config, "8.0.30")
+	callback := reconnectCallback{attempts: make(chan int, 1)}
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(callback)
+	}()
+	fake.acceptDump()
+	fake.conn.Close()
+	select {
+	case <-callback.attempts:
+	case err := <-result:
+		t.Fatal("Replicate:", err)
+	}
+	if err := server.Close(); err != nil {
+		t.Error("Close:", err)
+	}
+	if err := waitReplicate(t, result); err != nil {
+		t.Error("Replicate:", err)
+	}
+	if server.state != UNCONNECTED {
+		t.Error("state=", server.state)
+	}
+}
+
+// 在回调中Close
+type closeCallback struct {
+	testCallback
+	server *MysqlServer
+}
+
+func (this closeCallback) OnQuery(sql string) {
+	this.server.Close()
+}
+
+func TestCloseInCallback(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	config.Reconnect = true
+	config.ReconnectBackoff = time.Millisecond
+	fake, server := openFakeServer(t, config, "8.0.30")
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(closeCallback{server: server})
+	}()
+	fake.acceptDump()
+	fake.conn.Write(testQueryEventAt(300, "db", "BEGIN"))
+	if err := waitReplicate(t, result); err != nil {
+		t.Error("Replicate:", err)
+	}
+	if server.state != UNCONNECTED {
+		t.Error("state=", server.state)
+	}
+	if _, err := fake.conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection is not closed")
+	}
+	// 可以再次Close
+	if err := server.Close(); err != nil {
+		t.Error("Close again:", err)
+	}
+}
+
+type queryCallback struct {
+	testCallback
+	queries chan string
+}
+
+func (this queryCallback) OnQuery(sql string) {
+	this.queries <- sql
+}
+
+// QUERY_EVENT，schema是当前库
+func testQueryEventAt(logPos uint32, schema, query string) []byte {
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, byte(len(schema)), 0, 0, 0, 0}
+	body = append(append(body, schema...), 0)
+	return testEventPacketAt(EventTypeQueryEvent, logPos, append(body, query...))
+}
+
+type queryHistoryCallback struct {
+	queryCallback
+	histories chan QueryHistory
+}
+
+func (this queryHistoryCallback) OnQueryHistory(query QueryHistory) {
+	this.histories <- query
+}
+
+// 只实现CallbackInterface时OnQuery得到SQL，实现了QueryHistoryCallbackInterface时只调用OnQueryHistory
+func TestReplicateQueryCallback(t *testing.T) {
+	// 执行了3秒，错误码1062
+	body := append([]byte{1, 0, 0, 0, 3, 0, 0, 0, 2, 0x26, 0x04, 0, 0}, "db\x00INSERT INTO t VALUES (1)"...)
+	for _, withHistory := range []bool{false, true} {
+		config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+		fake, server := openFakeServer(t, config, "8.0.30")
+		plain := queryCallback{queries: make(chan string, 1)}
+		var callback CallbackInterface = plain
+		histories := make(chan QueryHistory, 1)
+		if withHistory {
+			callback = queryHistoryCallback{plain, histories}
+		}
+		result := make(chan error, 1)
+		go func() {
+			result <- server.Replicate(callback)
+		}()
+		fake.acceptDump()
+		fake.conn.Write(testEventPacketAt(EventTypeQueryEvent, 300, body))
+		if withHistory {
+			select {
+			case history := <-histories:
+				if history.Schema != "db" || history.Query != "INSERT INTO t VALUES (1)" || history.ExecutionTime != 3 || history.ErrorCode != 1062 {
+					t.Error("history=", history)
+				}
+				if history.EventMeta.Binlog.Filename != "mysql-bin.000001" || history.EventMeta.LogPos != 300 {
+					t.Error("EventMeta=", history.EventMeta)
+				}
+			case err := <-result:
+				t.Fatal("Replicate:", err)
+			}
+		} else {
+			select {
+			case query := <-plain.queries:
+				if query != "INSERT INTO t VALUES (1)" {
+					t.Error("query=", query)
+				}
+			case err := <-result:
+				t.Fatal("Replicate:", err)
+			}
+		}
+		server.Close()
+		if err := waitReplicate(t, result); err != nil {
+			t.Error("Replicate:", err)
+		}
+		if withHistory && len(plain.queries) != 0 {
+			t.Error("OnQuery called with OnQueryHistory, query=", <-plain.queries)
+		}
+	}
+}
+
+// 从指定位置开始时，之前的DDL不回调，但要更新表结构
+func TestReplicateSkipDDL(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 1000}}
+	fake, server := openFakeServer(t, config, "8.0.30")
+	callback := queryCallback{queries: make(chan string, 1)}
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(callback)
+	}()
+	// 从文件开头读，跳过1000之前的event
+	if binlog := fake.acceptDump(); binlog != (BinglogType{"mysql-bin.000001", 4}) {
+		t.Fatal("dump from", binlog)
+	}
+	fake.conn.Write(testQueryEventAt(500, "db", "CREATE TABLE t (id INT UNSIGNED, name VARCHAR(20))"))
+	fake.conn.Write(testQueryEventAt(1100, "db", "BEGIN"))
+	select {
+	case query := <-callback.queries:
+		if query != "BEGIN" {
+			t.Error("query=", query)
+		}
+	case err := <-result:
+		t.Fatal("Replicate:", err)
+	}
+	tableAttr := server.serverConfig.Columns["db"]["t"]
+	if len(tableAttr) != 2 || tableAttr[0].Name != "id" || !tableAttr[0].Unsigned || tableAttr[1].Name != "name" {
+		t.Error("Columns=", tableAttr)
+	}
+	server.Close()
+	if err := <-result; err != nil {
+		t.Error("Replicate:", err)
+	}
+}
+
+type endCallback struct {
+	testCallback
+}
+
+func (this endCallback) OnEnd() bool { return true }
+
+// 双方都支持CLIENT_DEPRECATE_EOF时，binlog读完后服务器发送0xFE开头的OK
+func TestReplicateDeprecateEOF(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	fake, server := newFakeServer(t, config)
+	fake.flags |= Uint4(CapabilityFlag_CLIENT_DEPRECATE_EOF)
+	result := openAsync(server)
+	fake.acceptHandshake("8.0.30")
+	checkOpened(t, server, result)
+	go func() {
+		result <- server.Replicate(endCallback{})
+	}()
+	fake.acceptDump()
+	fake.writePacket(1, []byte{0xfe, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Error("Replicate:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Replicate not returned")
+	}
+}
+
+func TestReconnectNotNetworkError(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"mysql-bin.000001", 4}}
+	config.Reconnect = true
+	fake, server := openFakeServer(t, config, "5.5.62-log")
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(testCallback{})
+	}()
+	fake.readPacket(0)
+	// 服务器返回的错误不重连
+	errPacket := append([]byte{0xff, 0x15, 0x04, '#'}, "28000Access denied"...)
+	fake.writePacket(1, errPacket)
+	if err, ok := (<-result).(MysqlError); !ok || err.Code != MYSQL_ERROR {
+		t.Error("err=", err)
+	}
+}
+
+func TestReconnectWait(t *testing.T) {
+	server := NewMysqlServer(Config{ReconnectBackoff: 100 * time.Millisecond, MaxReconnectWait: time.Second}, nil, nil)
+	// 每次翻倍，不超过MaxReconnectWait
+	for i, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for j := 0; j < 10; j++ {
+			if wait := server.reconnectWait(i + 1); wait < max/2 || wait > max {
+				t.Errorf("attempt=%d wait=%v", i+1, wait)
+			}
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// 服务器返回的ErrPacket
+func testErrPacket(code Uint2, message string) []byte {
+	payload := []byte{0xff, byte(code), byte(code >> 8), '#', 'H', 'Y', '0', '0', '0'}
+	return append(payload, message...)
+}
+
+func waitReplicate(t *testing.T, result chan error) error {
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Replicate not returned")
+	}
+	return nil
+}
+
+// 从还存在的最早的binlog开始，已经被purge时返回BinlogPurgedError
+func TestDumpFromBeginningPurged(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromBeginning}
+	// 5.6.2之前没有checksum，直接注册
+	fake, server := openFakeServer(t, config, "5.5.62-log")
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(testCallback{})
+	}()
+	if com := fake.readPacket(0); com[0] != 0x15 {
+		t.Fatalf("COM_REGISTER_SLAVE=% x", com)
+	}
+	fake.writeOK(1)
+	if com := fake.readPacket(0); string(com) != "\x03SHOW BINARY LOGS" {
+		t.Fatalf("COM_QUERY=%q", com)
+	}
+	fake.writeResultSetRows(1, []string{"Log_name", "File_size"}, [][]string{{"binlog.000007", "180"}, {"binlog.000008", "1024"}})
+	com := fake.readPacket(0)
+	if binlog := (BinglogType{string(com[11:]), binary.LittleEndian.Uint32(com[1:5])}); com[0] != 0x12 || binlog != (BinglogType{"binlog.000007", 4}) {
+		t.Fatalf("COM_BINLOG_DUMP=% x", com)
+	}
+	// 在SHOW BINARY LOGS之后被purge
+	message := "Could not find first log file name in binary log index file"
+	fake.writePacket(1, testErrPacket(ER_MASTER_FATAL_ERROR_READING_BINLOG, message))
+	err := waitReplicate(t, result)
+	if purged, ok := err.(BinlogPurgedError); !ok || purged.Filename != "binlog.000007" || purged.BinlogPos != 4 || purged.Message != message {
+		t.Errorf("err=%#v", err)
+	}
+	closeFakeServer(t, fake, server)
+}
+
+func TestDumpErrPacket(t *testing.T) {
+	cases := []struct {
+		message string
+		purged  bool
+	}{
+		{"Could not find first log file name in binary log index file", true},
+		{"Cannot replicate because the master purged required binary logs.", true},
+		{"Client requested master to start replication from position > file size", false},
+	}
+	for _, c := range cases {
+		config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"binlog.000003", 120}}
+		fake, server := openFakeServer(t, config, "8.0.30")
+		result := make(chan error, 1)
+		go func() {
+			result <- server.Replicate(testCallback{})
+		}()
+		fake.acceptDump()
+		fake.writePacket(1, testErrPacket(ER_MASTER_FATAL_ERROR_READING_BINLOG, c.message))
+		err := waitReplicate(t, result)
+		if c.purged {
+			if purged, ok := err.(BinlogPurgedError); !ok || purged.Filename != "binlog.000003" || purged.BinlogPos != 4 {
+				t.Errorf("message=%v err=%#v", c.message, err)
+			}
+		} else if mysqlError, ok := err.(MysqlError); !ok || mysqlError.Code != MYSQL_ERROR {
+			t.Errorf("message=%v err=%#v", c.message, err)
+		}
+		closeFakeServer(t, fake, server)
+	}
+}
+
+// 每个QUERY_EVENT时记录当前位置，binlog读完时结束复制
+type positionCallback struct {
+	testCallback
+	server    *MysqlServer
+	positions *[]BinglogType
+}
+
+func (this positionCallback) OnQuery(sql string) {
+	*this.positions = append(*this.positions, this.server.Position())
+}
+func (this positionCallback) OnEnd() bool { return true }
+
+func testRotateEventAt(logPos uint32, filename string, position uint64) []byte {
+	body := binary.LittleEndian.AppendUint64(nil, position)
+	return testEventPacketAt(EventTypeRotateEvent, logPos, append(body, filename...))
+}
+
+func TestPosition(t *testing.T) {
+	config := Config{User: "repl", ServerId: 2, DumpFrom: DumpFromPosition, BinlogPosition: BinglogType{"binlog.000001", 4}}
+	fake, server := openFakeServer(t, config, "8.0.30")
+	positions := []BinglogType{}
+	result := make(chan error, 1)
+	go func() {
+		result <- server.Replicate(positionCallback{server: server, positions: &positions})
+	}()
+	fake.acceptDump()
+	// 服务器伪造的rotate告诉开始的文件，LogPos为0
+	fake.conn.Write(testRotateEventAt(0, "binlog.000001", 4))
+	// 其它event的LogPos是下一个event的位置
+	fake.conn.Write(testQueryEventAt(300, "db", "BEGIN"))
+	fake.conn.Write(testQueryEventAt(400, "db", "COMMIT"))
+	// 文件末尾的rotate切换到下一个文件的开头
+	fake.conn.Write(testRotateEventAt(450, "binlog.000002", 4))
+	fake.conn.Write(testQueryEventAt(200, "db", "BEGIN"))
+	fake.conn.Write(testQueryEventAt(260, "db", "COMMIT"))
+	fake.conn.Write(testRotateEventAt(300, "binlog.000003", 4))
+	fake.writePacket(1, []byte{0xfe, 0x00, 0x00, 0x02, 0x00})
+	if err := waitReplicate(t, result); err != nil {
+		t.Fatal("Replicate:", err)
+	}
+	expected := []BinglogType{{"binlog.000001", 300}, {"binlog.000001", 400}, {"binlog.000002", 200}, {"binlog.000002", 260}}
+	if len(positions) != len(expected) {
+		t.Fatal("positions=", positions)
+	}
+	for idx := range expected {
+		if positions[idx] != expected[idx] {
+			t.Errorf("position %d=%v expected=%v", idx, positions[idx], expected[idx])
+		}
+	}
+	if position := server.Position(); position != (BinglogType{"binlog.000003", 4}) {
+		t.Error("Position=", position)
+	}
+	closeFakeServer(t, fake, server)
+}
+
+// MySQL 8.0的服务器设置了几乎所有的标志位。DumpFromLatest的SHOW MASTER STATUS，
+// 客户端不回应CLIENT_DEPRECATE_EOF时结果集以EOF结束，回应时以0xFE开头的OK结束，都能解析
+func TestDumpFromLatest(t *testing.T) {
+	for _, deprecateEOF := range []bool{false, true} {
+		fake, server := newFakeServer(t, Config{User: "repl", ServerId: 2, DumpFrom: DumpFromLatest})
+		fake.flags = 0xdfffffff
+		end := []byte{0xfe, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+		if !deprecateEOF {
+			fake.flags &^= Uint4(CapabilityFlag_CLIENT_DEPRECATE_EOF)
+			end = []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+		}
+		result := openAsync(server)
+		fake.acceptHandshake("8.0.30")
+		checkOpened(t, server, result)
+		go func() {
+			result <- server.Replicate(endCallback{})
+		}()
+		if com := fake.readPacket(0); com[0] != 0x03 {
+			t.Fatalf("COM_QUERY=%q", com)
+		}
+		fake.writeOK(1)
+		fake.readPacket(0)
+		fake.writeResultSet(1, []string{"@master_binlog_checksum"}, []string{"CRC32"})
+		if com := fake.readPacket(0); com[0] != 0x15 {
+			t.Fatalf("COM_REGISTER_SLAVE=% x", com)
+		}
+		fake.writeOK(1)
+		if com := fake.readPacket(0); string(com) != "\x03SHOW MASTER STATUS" {
+			t.Fatalf("COM_QUERY=%q", com)
+		}
+		fake.writeResultSet(1, []string{"File", "Position", "Binlog_Do_DB"}, []string{"binlog.000009", "157", ""})
+		// 从文件开头读，跳过157之前的event
+		com := fake.readPacket(0)
+		if binlog := (BinglogType{string(com[11:]), binary.LittleEndian.Uint32(com[1:5])}); com[0] != 0x12 || binlog != (BinglogType{"binlog.000009", 4}) {
+			t.Fatalf("deprecateEOF=%v COM_BINLOG_DUMP=% x", deprecateEOF, com)
+		}
+		fake.writePacket(1, end)
+		if err := waitReplicate(t, result); err != nil {
+			t.Error("deprecateEOF=", deprecateEOF, " Replicate:", err)
+		}
+		closeFakeServer(t, fake, server)
+	}
+}
@@ -0,0 +1,380 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 同一个server上连续的一段事务号
+type GTIDInterval struct {
+	Start int64 // 第一个事务号
+	Stop  int64 // 最后一个事务号+1。与mysql二进制格式相同，是开区间
+}
+
+// GTID的集合，对应mysql的gtid_executed。文本格式为 uuid:1-5:7,uuid2:1-10
+// server uuid(小写，带-) => 按Start排序、互不相连的区间
+type GTIDSet map[string][]GTIDInterval
+
+func NewGTIDSet() GTIDSet {
+	return make(GTIDSet)
+}
+
+// 解析文本格式的GTID集合。空字符串表示空集合
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	ret := NewGTIDSet()
+	for _, sidStr := range strings.Split(s, ",") {
+		// SELECT @@gtid_executed 返回的字符串中，每个uuid之间还有换行
+		sidStr = strings.TrimSpace(sidStr)
+		if sidStr == "" {
+			continue
+		}
+		parts := strings.Split(sidStr, ":")
+		if len(parts) < 2 {
+			return nil, Error{fmt.Sprintf("Invalid GTID set %v", sidStr), 0}
+		}
+		sid, err := parseUUID(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		uuid := formatUUID(sid)
+		for _, intervalStr := range parts[1:] {
+			var interval GTIDInterval
+			bounds := strings.SplitN(strings.TrimSpace(intervalStr), "-", 2)
+			if interval.Start, err = strconv.ParseInt(bounds[0], 10, 64); err != nil {
+				return nil, Error{fmt.Sprintf("Invalid GTID interval %v", intervalStr), 0}
+			}
+			interval.Stop = interval.Start
+			if len(bounds) == 2 {
+				if interval.Stop, err = strconv.ParseInt(bounds[1], 10, 64); err != nil {
+					return nil, Error{fmt.Sprintf("Invalid GTID interval %v", intervalStr), 0}
+				}
+			}
+			interval.Stop++
+			if interval.Start < 1 || interval.Stop <= interval.Start {
+				return nil, Error{fmt.Sprintf("Invalid GTID interval %v", intervalStr), 0}
+			}
+			ret.addInterval(uuid, interval)
+		}
+	}
+	return ret, nil
+}
+
+// 把一个事务加入集合
+func (this GTIDSet) Add(uuid string, gno int64) {
+	this.addInterval(strings.ToLower(uuid), GTIDInterval{gno, gno + 1})
+}
+func (this GTIDSet) addInterval(uuid string, interval GTIDInterval) {
+	intervals := append(this[uuid], interval)
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start < intervals[j].Start
+	})
+	// 合并重叠或相连的区间
+	merged := intervals[:1]
+	for _, cur := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if cur.Start <= last.Stop {
+			if cur.Stop > last.Stop {
+				last.Stop = cur.Stop
+			}
+		} else {
+			merged = append(merged, cur)
+		}
+	}
+	this[uuid] = merged
+}
+
+// 按uuid排序，保证输出是确定的
+func (this GTIDSet) sortedUUIDs() []string {
+	ret := make([]string, 0, len(this))
+	for uuid, intervals := range this {
+		if len(intervals) > 0 {
+			ret = append(ret, uuid)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+func (this GTIDSet) String() string {
+	buf := bytes.NewBufferString("")
+	for idx, uuid := range this.sortedUUIDs() {
+		if idx > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(uuid)
+		for _, interval := range this[uuid] {
+			if interval.Stop == interval.Start+1 {
+				buf.WriteString(fmt.Sprintf(":%d", interval.Start))
+			} else {
+				buf.WriteString(fmt.Sprintf(":%d-%d", interval.Start, interval.Stop-1))
+			}
+		}
+	}
+	return buf.String()
+}
+
+// 转成COM_BINLOG_DUMP_GTID中用的SID-block格式
+// n_sids(8) { sid(16) n_intervals(8) { start(8) stop(8) }... }...
+func (this GTIDSet) Decode() []byte {
+	uuids := this.sortedUUIDs()
+	nSids := Uint8(len(uuids))
+	ret := nSids.Decode()
+	for _, uuid := range uuids {
+		sid, _ := parseUUID(uuid)
+		ret = append(ret, sid...)
+		nIntervals := Uint8(len(this[uuid]))
+		ret = append(ret, nIntervals.Decode()...)
+		for _, interval := range this[uuid] {
+			start := Uint8(interval.Start)
+			stop := Uint8(interval.Stop)
+			ret = append(ret, start.Decode()...)
+			ret = append(ret, stop.Decode()...)
+		}
+	}
+	return ret
+}
+
+// 从SID-block格式解析，结果加入this
+func (this GTIDSet) Encode(b []byte) error {
+	if len(b) < 8 {
+		return Error{fmt.Sprintf("GTID set too short %v", b), 0}
+	}
+	nSids := int(byte2uint64(b, Uint8Length))
+	b = b[8:]
+	for i := 0; i < nSids; i++ {
+		if len(b) < 16+8 {
+			return Error{fmt.Sprintf("GTID set too short %v", b), 0}
+		}
+		uuid := formatUUID(b[:16])
+		nIntervals := int(byte2uint64(b[16:], Uint8Length))
+		b = b[16+8:]
+		if len(b) < nIntervals*16 {
+			return Error{fmt.Sprintf("GTID set too short %v", b), 0}
+		}
+		for j := 0; j < nIntervals; j++ {
+			interval := GTIDInterval{int64(byte2uint64(b, Uint8Length)), int64(byte2uint64(b[8:], Uint8Length))}
+			if interval.Start < 1 || interval.Stop <= interval.Start {
+				return Error{fmt.Sprintf("Invalid GTID interval %v-%v", interval.Start, interval.Stop), 0}
+			}
+			this.addInterval(uuid, interval)
+			b = b[16:]
+		}
+	}
+	return nil
+}
+
+func (this GTIDSet) Clone() GTIDSet {
+	ret := NewGTIDSet()
+	for uuid, intervals := range this {
+		if len(intervals) > 0 {
+			ret[uuid] = append([]GTIDInterval{}, intervals...)
+		}
+	}
+	return ret
+}
+func (this GTIDSet) IsEmpty() bool {
+	return len(this.sortedUUIDs()) == 0
+}
+
+// 并集
+func (this GTIDSet) Union(other GTIDSet) GTIDSet {
+	ret := this.Clone()
+	for uuid, intervals := range other {
+		for _, interval := range intervals {
+			ret.addInterval(uuid, interval)
+		}
+	}
+	return ret
+}
+
+// 交集
+func (this GTIDSet) Intersect(other GTIDSet) GTIDSet {
+	ret := NewGTIDSet()
+	for uuid, intervals := range this {
+		otherIntervals := other[uuid]
+		// 两个都是有序的，依次比较
+		i, j := 0, 0
+		for i < len(intervals) && j < len(otherIntervals) {
+			start := intervals[i].Start
+			if otherIntervals[j].Start > start {
+				start = otherIntervals[j].Start
+			}
+			stop := intervals[i].Stop
+			if otherIntervals[j].Stop < stop {
+				stop = otherIntervals[j].Stop
+			}
+			if start < stop {
+				ret[uuid] = append(ret[uuid], GTIDInterval{start, stop})
+			}
+			if intervals[i].Stop < otherIntervals[j].Stop {
+				i++
+			} else {
+				j++
+			}
+		}
+	}
+	return ret
+}
+
+// 差集：在this中但不在other中的事务
+func (this GTIDSet) Subtract(other GTIDSet) GTIDSet {
+	ret := NewGTIDSet()
+	for uuid, intervals := range this {
+		otherIntervals := other[uuid]
+		for _, interval := range intervals {
+			start := interval.Start
+			for _, otherInterval := range otherIntervals {
+				if otherInterval.Stop <= start || otherInterval.Start >= interval.Stop {
+					continue
+				}
+				if otherInterval.Start > start {
+					ret[uuid] = append(ret[uuid], GTIDInterval{start, otherInterval.Start})
+				}
+				start = otherInterval.Stop
+				if start >= interval.Stop {
+					break
+				}
+			}
+			if start < interval.Stop {
+				ret[uuid] = append(ret[uuid], GTIDInterval{start, interval.Stop})
+			}
+		}
+	}
+	return ret
+}
+
+// other中的事务是否都在this中。用来判断一个从库是否已经追上另一个
+func (this GTIDSet) Contains(other GTIDSet) bool {
+	return other.Subtract(this).IsEmpty()
+}
+func (this GTIDSet) ContainsGTID(uuid string, gno int64) bool {
+	for _, interval := range this[strings.ToLower(uuid)] {
+		if interval.Start <= gno && gno < interval.Stop {
+			return true
+		}
+	}
+	return false
+}
+func (this GTIDSet) Equal(other GTIDSet) bool {
+	return this.String() == other.String()
+}
+
+// uuid的文本格式(3e11fa47-71ca-11e1-9e33-c80aa9429562)转成16字节
+func parseUUID(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	ret, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(ret) != 16 {
+		return nil, Error{fmt.Sprintf("Invalid server uuid %v", s), 0}
+	}
+	return ret, nil
+}
+func formatUUID(b []byte) string {
+	s := hex.EncodeToString(b)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// MariaDB的GTID：domain_id-server_id-seq_no，如 0-1-100
+type MariadbGTID struct {
+	DomainId       uint32
+	ServerId       uint32
+	SequenceNumber uint64
+}
+
+func ParseMariadbGTID(s string) (MariadbGTID, error) {
+	var ret MariadbGTID
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 3 {
+		return ret, Error{fmt.Sprintf("Invalid MariaDB GTID %v", s), 0}
+	}
+	domainId, err1 := strconv.ParseUint(parts[0], 10, 32)
+	serverId, err2 := strconv.ParseUint(parts[1], 10, 32)
+	sequenceNumber, err3 := strconv.ParseUint(parts[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return ret, Error{fmt.Sprintf("Invalid MariaDB GTID %v", s), 0}
+	}
+	ret.DomainId = uint32(domainId)
+	ret.ServerId = uint32(serverId)
+	ret.SequenceNumber = sequenceNumber
+	return ret, nil
+}
+func (this MariadbGTID) String() string {
+	return fmt.Sprintf("%d-%d-%d", this.DomainId, this.ServerId, this.SequenceNumber)
+}
+
+// MariaDB的GTID位置，对应gtid_slave_pos。同一个domain中的事务是按顺序执行的，所以每个domain只记最后一个
+// domain_id => 最后一个GTID
+type MariadbGTIDSet map[uint32]MariadbGTID
+
+func NewMariadbGTIDSet() MariadbGTIDSet {
+	return make(MariadbGTIDSet)
+}
+
+// 解析 0-1-100,1-2-50 这样的字符串
+func ParseMariadbGTIDSet(s string) (MariadbGTIDSet, error) {
+	ret := NewMariadbGTIDSet()
+	for _, gtidStr := range strings.Split(s, ",") {
+		if strings.TrimSpace(gtidStr) == "" {
+			continue
+		}
+		gtid, err := ParseMariadbGTID(gtidStr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ret[gtid.DomainId]; ok {
+			return nil, Error{fmt.Sprintf("Duplicate domain %v in MariaDB GTID set %v", gtid.DomainId, s), 0}
+		}
+		ret[gtid.DomainId] = gtid
+	}
+	return ret, nil
+}
+
+// 执行完一个事务后更新这个domain的位置
+func (this MariadbGTIDSet) Add(gtid MariadbGTID) {
+	if last, ok := this[gtid.DomainId]; !ok || last.SequenceNumber < gtid.SequenceNumber {
+		this[gtid.DomainId] = gtid
+	}
+}
+func (this MariadbGTIDSet) String() string {
+	domainIds := make([]int, 0, len(this))
+	for domainId := range this {
+		domainIds = append(domainIds, int(domainId))
+	}
+	sort.Ints(domainIds)
+	gtids := make([]string, 0, len(domainIds))
+	for _, domainId := range domainIds {
+		gtids = append(gtids, this[uint32(domainId)].String())
+	}
+	return strings.Join(gtids, ",")
+}
+func (this MariadbGTIDSet) Clone() MariadbGTIDSet {
+	ret := NewMariadbGTIDSet()
+	for domainId, gtid := range this {
+		ret[domainId] = gtid
+	}
+	return ret
+}
+
+// 并集：每个domain取较新的位置
+func (this MariadbGTIDSet) Union(other MariadbGTIDSet) MariadbGTIDSet {
+	ret := this.Clone()
+	for _, gtid := range other {
+		ret.Add(gtid)
+	}
+	return ret
+}
+
+// other的每个domain，this都执行到了相同或更新的位置
+func (this MariadbGTIDSet) Contains(other MariadbGTIDSet) bool {
+	for domainId, gtid := range other {
+		if last, ok := this[domainId]; !ok || last.SequenceNumber < gtid.SequenceNumber {
+			return false
+		}
+	}
+	return true
+}
+func (this MariadbGTIDSet) Equal(other MariadbGTIDSet) bool {
+	return this.String() == other.String()
+}
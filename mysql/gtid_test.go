@@ -0,0 +1,265 @@
+package mysql
+
+import (
+	"bytes"
+	"hash/crc32"
+	"net"
+	"testing"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{"", ""},
+		{"3E11FA47-71CA-11E1-9E33-C80AA9429562:23", "3e11fa47-71ca-11e1-9e33-c80aa9429562:23"},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:6-8:10", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-8:10"},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:7-9:1-3:2-4", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-4:7-9"},
+		// SELECT @@gtid_executed 的格式，uuid之间有换行
+		{"fc3a0f8e-7d0a-11e9-8ae7-0242ac110002:1-10,\n3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,fc3a0f8e-7d0a-11e9-8ae7-0242ac110002:1-10"},
+	}
+	for _, c := range cases {
+		gtidSet, err := ParseGTIDSet(c.in)
+		if err != nil {
+			t.Error("in=", c.in, " err=", err)
+			continue
+		}
+		if gtidSet.String() != c.expected {
+			t.Error("in=", c.in, " out=", gtidSet.String(), " expected=", c.expected)
+		}
+	}
+
+	for _, in := range []string{"3e11fa47-71ca-11e1-9e33-c80aa9429562", "3e11fa47:1-5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1", "3e11fa47-71ca-11e1-9e33-c80aa9429562:0", "3e11fa47-71ca-11e1-9e33-c80aa9429562:a"} {
+		if _, err := ParseGTIDSet(in); err == nil {
+			t.Error("in=", in, " expected error")
+		}
+	}
+}
+
+func TestGTIDSetAdd(t *testing.T) {
+	gtidSet, _ := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	gtidSet.Add("3e11fa47-71ca-11e1-9e33-c80aa9429562", 6)
+	gtidSet.Add("3e11fa47-71ca-11e1-9e33-c80aa9429562", 8)
+	gtidSet.Add("fc3a0f8e-7d0a-11e9-8ae7-0242ac110002", 1)
+	expected := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6:8,fc3a0f8e-7d0a-11e9-8ae7-0242ac110002:1"
+	if gtidSet.String() != expected {
+		t.Error("out=", gtidSet.String(), " expected=", expected)
+	}
+	gtidSet.Add("3e11fa47-71ca-11e1-9e33-c80aa9429562", 7)
+	expected = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-8,fc3a0f8e-7d0a-11e9-8ae7-0242ac110002:1"
+	if gtidSet.String() != expected {
+		t.Error("out=", gtidSet.String(), " expected=", expected)
+	}
+}
+
+func TestGTIDSetDecode(t *testing.T) {
+	gtidSet, _ := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7")
+	expected := []byte{
+		0x01, 0, 0, 0, 0, 0, 0, 0, // n_sids
+		0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62,
+		0x02, 0, 0, 0, 0, 0, 0, 0, // n_intervals
+		0x01, 0, 0, 0, 0, 0, 0, 0, 0x06, 0, 0, 0, 0, 0, 0, 0,
+		0x07, 0, 0, 0, 0, 0, 0, 0, 0x08, 0, 0, 0, 0, 0, 0, 0,
+	}
+	if out := gtidSet.Decode(); !bytes.Equal(out, expected) {
+		t.Error("out=", out, " expected=", expected)
+	}
+	if out := NewGTIDSet().Decode(); !bytes.Equal(out, make([]byte, 8)) {
+		t.Error("empty set out=", out)
+	}
+}
+
+// 把event包装成dump时服务器发来的packet：OK头 + event header + body + crc
+func testEventPacket(eventType Uint1, body []byte) []byte {
+	payload := []byte{0x00}
+	header := EventHeaderType{}
+	header.EventType = eventType
+	header.EventSize = Uint4(19 + len(body) + 4)
+	payload = append(payload, header.Timestamp.Decode()...)
+	payload = append(payload, header.EventType.Decode()...)
+	payload = append(payload, header.ServerId.Decode()...)
+	payload = append(payload, header.EventSize.Decode()...)
+	payload = append(payload, header.LogPos.Decode()...)
+	payload = append(payload, header.Flags.Decode()...)
+	payload = append(payload, body...)
+	crc := Uint4(crc32.ChecksumIEEE(payload[1:]))
+	payload = append(payload, crc.Decode()...)
+	length := Uint3(len(payload))
+	return append(append(length.Decode(), 0x01), payload...)
+}
+
+type testLog struct {
+	t *testing.T
+}
+
+func (this testLog) Log(logTag uint32, content string) {
+	this.t.Log(content)
+}
+
+// 用net.Pipe模拟服务器，读出一个event
+func readTestEvent(t *testing.T, pkt []byte) interface{} {
+	event, err := readTestEventWith(t, pkt, NewConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullEvent, ok := event.(FullEvent)
+	if !ok {
+		t.Fatal("not an event:", event)
+	}
+	return fullEvent.event
+}
+
+// 与readTestEvent相同，返回读event时的错误
+func readTestEventWith(t *testing.T, pkt []byte, config *Config) (interface{}, error) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go server.Write(pkt)
+	stream := NewMysqlStream(client)
+	stream.log = testLog{t}
+	stream.config = config
+	stream.serverConfig = NewServerConfig()
+	stream.serverConfig.Version = "8.0.30"
+	stream.serverConfig.BinlogVersion = 4
+	stream.serverConfig.ServerCrc32CheckFlag = true
+	stream.serverConfig.CrcSize = 4
+	stream.serverConfig.BinlogFilename = "mysql-bin.000001"
+	return stream.ReadEvent()
+}
+
+func TestGtidEvent(t *testing.T) {
+	body := []byte{0x01}
+	body = append(body, 0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62)
+	body = append(body, 0x17, 0, 0, 0, 0, 0, 0, 0) // gno=23
+	body = append(body, 0x02)                      // lt_type
+	body = append(body, 0x05, 0, 0, 0, 0, 0, 0, 0) // last_committed
+	body = append(body, 0x06, 0, 0, 0, 0, 0, 0, 0) // sequence_number
+	// immediate_commit_timestamp=1600000000000001，最高位为1，后面有original_commit_timestamp=1600000000000000
+	body = append(body, 0x01, 0x00, 0xa4, 0x07, 0x31, 0xaf, 0x85)
+	body = append(body, 0x00, 0x00, 0xa4, 0x07, 0x31, 0xaf, 0x05)
+	body = append(body, 0xfc, 0x00, 0x01)       // transaction_length=256
+	body = append(body, 0x80, 0x38, 0x01, 0x80) // immediate_server_version=80000，最高位为1
+	body = append(body, 0x5e, 0x38, 0x01, 0x00) // original_server_version=79966
+
+	event, ok := readTestEvent(t, testEventPacket(EventTypeGtidEvent, body)).(GtidEventType)
+	if !ok {
+		t.Fatal("not GtidEventType")
+	}
+	if event.GTID() != "3e11fa47-71ca-11e1-9e33-c80aa9429562:23" || event.CommitFlag != 1 {
+		t.Error("event=", event)
+	}
+	if event.LtType != 2 || event.LastCommitted != 5 || event.SequenceNumber != 6 {
+		t.Error("logical clock, event=", event)
+	}
+	if event.ImmediateCommitTimestamp != 1600000000000001 || event.OriginalCommitTimestamp != 1600000000000000 {
+		t.Error("commit timestamp, event=", event)
+	}
+	if event.ImmediateCommitTime().UnixNano() != 1600000000000001000 {
+		t.Error("commit time=", event.ImmediateCommitTime())
+	}
+	if event.TransactionLength != 256 || event.ImmediateServerVersion != 80000 || event.OriginalServerVersion != 79966 {
+		t.Error("event=", event)
+	}
+
+	// 5.6的格式只有前三个字段
+	anonymous, ok := readTestEvent(t, testEventPacket(EventTypeAnonymousGtidEvent, make([]byte, 25))).(AnonymousGtidEventType)
+	if !ok {
+		t.Fatal("not AnonymousGtidEventType")
+	}
+	if anonymous.GNO != 0 || anonymous.LtType != 0 || anonymous.ImmediateCommitTimestamp != 0 {
+		t.Error("anonymous=", anonymous)
+	}
+}
+
+func TestPreviousGtidsEvent(t *testing.T) {
+	expected, _ := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,fc3a0f8e-7d0a-11e9-8ae7-0242ac110002:1-10")
+	event, ok := readTestEvent(t, testEventPacket(EventTypePreviousGtidsEvent, expected.Decode())).(PreviousGtidsEventType)
+	if !ok {
+		t.Fatal("not PreviousGtidsEventType")
+	}
+	if event.GTIDSet.String() != expected.String() {
+		t.Error("out=", event.GTIDSet, " expected=", expected)
+	}
+}
+
+func TestGTIDSetEncode(t *testing.T) {
+	expected, _ := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,fc3a0f8e-7d0a-11e9-8ae7-0242ac110002:1-10")
+	gtidSet := NewGTIDSet()
+	if err := gtidSet.Encode(expected.Decode()); err != nil {
+		t.Fatal(err)
+	}
+	if !gtidSet.Equal(expected) {
+		t.Error("out=", gtidSet, " expected=", expected)
+	}
+	if err := NewGTIDSet().Encode(expected.Decode()[:30]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+func TestGTIDSetOperations(t *testing.T) {
+	const uuid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	const uuid2 = "fc3a0f8e-7d0a-11e9-8ae7-0242ac110002"
+	a, _ := ParseGTIDSet(uuid1 + ":1-10:20-30," + uuid2 + ":1-5")
+	b, _ := ParseGTIDSet(uuid1 + ":5-25")
+	cases := []struct {
+		name     string
+		out      GTIDSet
+		expected string
+	}{
+		{"union", a.Union(b), uuid1 + ":1-30," + uuid2 + ":1-5"},
+		{"intersect", a.Intersect(b), uuid1 + ":5-10:20-25"},
+		{"subtract", a.Subtract(b), uuid1 + ":1-4:26-30," + uuid2 + ":1-5"},
+		{"subtract reverse", b.Subtract(a), uuid1 + ":11-19"},
+		{"subtract self", a.Subtract(a), ""},
+	}
+	for _, c := range cases {
+		if c.out.String() != c.expected {
+			t.Error(c.name, " out=", c.out, " expected=", c.expected)
+		}
+	}
+	// 运算不能修改原来的集合
+	if a.String() != uuid1+":1-10:20-30,"+uuid2+":1-5" || b.String() != uuid1+":5-25" {
+		t.Error("modified, a=", a, " b=", b)
+	}
+
+	if a.Contains(b) || b.Contains(a) {
+		t.Error("a and b should not contain each other")
+	}
+	if !a.Union(b).Contains(a) || !a.Contains(a.Intersect(b)) || !a.Contains(NewGTIDSet()) {
+		t.Error("Contains")
+	}
+	if !a.ContainsGTID(uuid1, 25) || a.ContainsGTID(uuid1, 15) || a.ContainsGTID(uuid2, 6) {
+		t.Error("ContainsGTID")
+	}
+}
+
+func TestMariadbGTIDSet(t *testing.T) {
+	gtidSet, err := ParseMariadbGTIDSet("1-2-50, 0-1-100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gtidSet.String() != "0-1-100,1-2-50" {
+		t.Error("out=", gtidSet)
+	}
+	gtidSet.Add(MariadbGTID{0, 3, 101})
+	gtidSet.Add(MariadbGTID{1, 2, 49})
+	if gtidSet.String() != "0-3-101,1-2-50" {
+		t.Error("after Add, out=", gtidSet)
+	}
+
+	other, _ := ParseMariadbGTIDSet("0-1-100,2-1-5")
+	if gtidSet.Contains(other) {
+		t.Error("domain 2 is missing")
+	}
+	union := gtidSet.Union(other)
+	if union.String() != "0-3-101,1-2-50,2-1-5" || !union.Contains(other) || !union.Contains(gtidSet) {
+		t.Error("union=", union)
+	}
+
+	for _, in := range []string{"0-1", "0-1-x", "0-1-1,0-2-2"} {
+		if _, err := ParseMariadbGTIDSet(in); err == nil {
+			t.Error("in=", in, " expected error")
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQL的二进制JSON格式，JSON列在binlog中就是这个格式
+// 第一个字节是类型，后面是值。对象和数组中的偏移都是相对于对象、数组开始(类型之后)的位置
+// 参考 https://dev.mysql.com/doc/dev/mysql-server/latest/json__binary_8h.html
+const (
+	JSONB_TYPE_SMALL_OBJECT byte = 0x00 // 元素个数、大小、偏移都是2个字节
+	JSONB_TYPE_LARGE_OBJECT byte = 0x01 // 元素个数、大小、偏移都是4个字节
+	JSONB_TYPE_SMALL_ARRAY  byte = 0x02
+	JSONB_TYPE_LARGE_ARRAY  byte = 0x03
+	JSONB_TYPE_LITERAL      byte = 0x04 // null、true、false
+	JSONB_TYPE_INT16        byte = 0x05
+	JSONB_TYPE_UINT16       byte = 0x06
+	JSONB_TYPE_INT32        byte = 0x07
+	JSONB_TYPE_UINT32       byte = 0x08
+	JSONB_TYPE_INT64        byte = 0x09
+	JSONB_TYPE_UINT64       byte = 0x0a
+	JSONB_TYPE_DOUBLE       byte = 0x0b
+	JSONB_TYPE_STRING       byte = 0x0c // 变长的长度，然后是utf8mb4的字符串
+	JSONB_TYPE_OPAQUE       byte = 0x0f // 1字节的列类型，变长的长度，然后是这个类型的二进制格式
+)
+
+const (
+	JSONB_NULL_LITERAL  byte = 0x00
+	JSONB_TRUE_LITERAL  byte = 0x01
+	JSONB_FALSE_LITERAL byte = 0x02
+)
+
+type JSONDecodeError struct {
+	msg string
+}
+
+func (this JSONDecodeError) Error() string {
+	return "Invalid JSON binary: " + this.msg
+}
+
+// 解码整个JSON值。长度为0时是JSON的null(如5.7中的部分更新)
+func decodeJSONBinary(data []byte) (ColumnValueJSONType, error) {
+	ret := ColumnValueJSONType{}
+	if len(data) == 0 {
+		ret.text = "null"
+		return ret, nil
+	}
+	decoder := jsonBinaryDecoder{bytes.NewBufferString("")}
+	value, err := decoder.decodeValue(data[0], data[1:])
+	if err != nil {
+		return ret, err
+	}
+	ret.Value = value
+	ret.text = decoder.text.String()
+	return ret, nil
+}
+
+// 解码的同时生成JSON文本。对象的键按存储的顺序输出，与MySQL的输出相同
+type jsonBinaryDecoder struct {
+	text *bytes.Buffer
+}
+
+func (this *jsonBinaryDecoder) decodeValue(valueType byte, data []byte) (interface{}, error) {
+	switch valueType {
+	case JSONB_TYPE_SMALL_OBJECT:
+		return this.decodeComposite(data, false, true)
+	case JSONB_TYPE_LARGE_OBJECT:
+		return this.decodeComposite(data, true, true)
+	case JSONB_TYPE_SMALL_ARRAY:
+		return this.decodeComposite(data, false, false)
+	case JSONB_TYPE_LARGE_ARRAY:
+		return this.decodeComposite(data, true, false)
+	case JSONB_TYPE_LITERAL:
+		if len(data) < 1 {
+			return nil, JSONDecodeError{"literal is truncated"}
+		}
+		switch data[0] {
+		case JSONB_NULL_LITERAL:
+			this.text.WriteString("null")
+			return nil, nil
+		case JSONB_TRUE_LITERAL:
+			this.text.WriteString("true")
+			return true, nil
+		case JSONB_FALSE_LITERAL:
+			this.text.WriteString("false")
+			return false, nil
+		}
+		return nil, JSONDecodeError{fmt.Sprintf("unknown literal %v", data[0])}
+	case JSONB_TYPE_INT16, JSONB_TYPE_INT32, JSONB_TYPE_INT64:
+		var ret int64
+		switch {
+		case valueType == JSONB_TYPE_INT16 && len(data) >= 2:
+			ret = int64(int16(binary.LittleEndian.Uint16(data)))
+		case valueType == JSONB_TYPE_INT32 && len(data) >= 4:
+			ret = int64(int32(binary.LittleEndian.Uint32(data)))
+		case valueType == JSONB_TYPE_INT64 && len(data) >= 8:
+			ret = int64(binary.LittleEndian.Uint64(data))
+		default:
+			return nil, JSONDecodeError{"integer is truncated"}
+		}
+		this.text.WriteString(strconv.FormatInt(ret, 10))
+		return ret, nil
+	case JSONB_TYPE_UINT16, JSONB_TYPE_UINT32, JSONB_TYPE_UINT64:
+		var ret uint64
+		switch {
+		case valueType == JSONB_TYPE_UINT16 && len(data) >= 2:
+			ret = uint64(binary.LittleEndian.Uint16(data))
+		case valueType == JSONB_TYPE_UINT32 && len(data) >= 4:
+			ret = uint64(binary.LittleEndian.Uint32(data))
+		case valueType == JSONB_TYPE_UINT64 && len(data) >= 8:
+			ret = binary.LittleEndian.Uint64(data)
+		default:
+			return nil, JSONDecodeError{"integer is truncated"}
+		}
+		this.text.WriteString(strconv.FormatUint(ret, 10))
+		return ret, nil
+	case JSONB_TYPE_DOUBLE:
+		if len(data) < 8 {
+			return nil, JSONDecodeError{"double is truncated"}
+		}
+		ret := math.Float64frombits(binary.LittleEndian.Uint64(data))
+		this.text.WriteString(formatJSONDouble(ret))
+		return ret, nil
+	case JSONB_TYPE_STRING:
+		buf, err := readJSONVariableBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		writeJSONString(this.text, string(buf))
+		return string(buf), nil
+	case JSONB_TYPE_OPAQUE:
+		if len(data) < 1 {
+			return nil, JSONDecodeError{"opaque is truncated"}
+		}
+		buf, err := readJSONVariableBytes(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		return this.decodeOpaque(ColumnType(data[0]), buf)
+	}
+	return nil, JSONDecodeError{fmt.Sprintf("unknown type %v", valueType)}
+}
+
+// 对象或数组：元素个数，总字节数，(对象的)各个键的偏移和长度，各个值的类型和偏移，然后是键和值
+// 较短的值直接放在偏移的位置上，不另外存放
+func (this *jsonBinaryDecoder) decodeComposite(data []byte, large bool, isObject bool) (interface{}, error) {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	if len(data) < 2*offsetSize {
+		return nil, JSONDecodeError{"header is truncated"}
+	}
+	count := readJSONOffset(data, 0, large)
+	size := readJSONOffset(data, offsetSize, large)
+	keyEntrySize := offsetSize + 2
+	valueEntrySize := 1 + offsetSize
+	headerSize := 2*offsetSize + count*valueEntrySize
+	if isObject {
+		headerSize += count * keyEntrySize
+	}
+	if size > len(data) || headerSize > size {
+		return nil, JSONDecodeError{fmt.Sprintf("size %d of %d elements is invalid", size, count)}
+	}
+	data = data[:size]
+
+	var object map[string]interface{}
+	var array []interface{}
+	if isObject {
+		object = make(map[string]interface{}, count)
+		this.text.WriteString("{")
+	} else {
+		array = make([]interface{}, 0, count)
+		this.text.WriteString("[")
+	}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			this.text.WriteString(", ")
+		}
+		var key string
+		valueEntry := 2*offsetSize + i*valueEntrySize
+		if isObject {
+			keyEntry := 2*offsetSize + i*keyEntrySize
+			keyOffset := readJSONOffset(data, keyEntry, large)
+			keyLength := int(binary.LittleEndian.Uint16(data[keyEntry+offsetSize:]))
+			if keyOffset+keyLength > size {
+				return nil, JSONDecodeError{fmt.Sprintf("key offset %d is out of range", keyOffset)}
+			}
+			key = string(data[keyOffset : keyOffset+keyLength])
+			writeJSONString(this.text, key)
+			this.text.WriteString(": ")
+			valueEntry += count * keyEntrySize
+		}
+		valueType := data[valueEntry]
+		var value interface{}
+		var err error
+		if isJSONInlined(valueType, large) {
+			value, err = this.decodeValue(valueType, data[valueEntry+1:valueEntry+1+offsetSize])
+		} else {
+			valueOffset := readJSONOffset(data, valueEntry+1, large)
+			if valueOffset >= size {
+				return nil, JSONDecodeError{fmt.Sprintf("value offset %d is out of range", valueOffset)}
+			}
+			value, err = this.decodeValue(valueType, data[valueOffset:])
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isObject {
+			object[key] = value
+		} else {
+			array = append(array, value)
+		}
+	}
+	if isObject {
+		this.text.WriteString("}")
+		return object, nil
+	}
+	this.text.WriteString("]")
+	return array, nil
+}
+
+// opaque是MySQL中没有对应的JSON类型的值，如日期时间、DECIMAL，以列的二进制格式保存
+func (this *jsonBinaryDecoder) decodeOpaque(columnType ColumnType, data []byte) (interface{}, error) {
+	switch columnType {
+	case ColumnTypeDate, ColumnTypeDatetime, ColumnTypeTimestamp:
+		if len(data) < 8 {
+			return nil, JSONDecodeError{"datetime is truncated"}
+		}
+		// 与DATETIME2相同的打包格式：年*13+月(17位)、日(5位)、时(5位)、分(6位)、秒(6位)，低24位是微秒
+		packed := int64(binary.LittleEndian.Uint64(data))
+		if packed < 0 {
+			packed = -packed
+		}
+		microSecond := packed % (1 << 24)
+		ymdhms := packed >> 24
+		ymd := ymdhms >> 17
+		ym := ymd >> 5
+		hms := ymdhms % (1 << 17)
+		year, month, day := ym/13, ym%13, ymd%(1<<5)
+		hour, minute, second := hms>>12, (hms>>6)%(1<<6), hms%(1<<6)
+		if columnType == ColumnTypeDate {
+			this.text.WriteString(fmt.Sprintf(`"%04d-%02d-%02d"`, year, month, day))
+			return NewColumnValueTime(DateOnlyFormat, Uint2(year), Uint1(month), Uint1(day), 0, 0, 0, 0), nil
+		}
+		this.text.WriteString(fmt.Sprintf(`"%04d-%02d-%02d %02d:%02d:%02d.%06d"`, year, month, day, hour, minute, second, microSecond))
+		return NewColumnValueTime(DateTimeNanoFormat, Uint2(year), Uint1(month), Uint1(day), Uint1(hour), Uint1(minute), Uint1(second), Uint4(microSecond)), nil
+	case ColumnTypeTime:
+		if len(data) < 8 {
+			return nil, JSONDecodeError{"time is truncated"}
+		}
+		// 时(10位)、分(6位)、秒(6位)，低24位是微秒，负数时整体取负
+		packed := int64(binary.LittleEndian.Uint64(data))
+		sign := ""
+		if packed < 0 {
+			sign = "-"
+			packed = -packed
+		}
+		microSecond := packed % (1 << 24)
+		hms := packed >> 24
+		hour, minute, second := (hms>>12)%(1<<10), (hms>>6)%(1<<6), hms%(1<<6)
+		this.text.WriteString(fmt.Sprintf(`"%s%02d:%02d:%02d.%06d"`, sign, hour, minute, second, microSecond))
+		ret := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second + time.Duration(microSecond)*time.Microsecond
+		if sign != "" {
+			ret = -ret
+		}
+		return ret, nil
+	case ColumnTypeNewDecimal:
+		// 精度和小数位数，然后是DECIMAL的二进制格式
+		if len(data) < 2 {
+			return nil, JSONDecodeError{"decimal is truncated"}
+		}
+		buf := data[2:]
+		ret, err := readDecimal(data[0], data[1], func(n int64) ([]byte, error) {
+			if int64(len(buf)) < n {
+				return nil, JSONDecodeError{"decimal is truncated"}
+			}
+			// readDecimal会修改读到的字节
+			ret := append([]byte{}, buf[:n]...)
+			buf = buf[n:]
+			return ret, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		this.text.WriteString(ret)
+		return ret, nil
+	}
+	// 其它类型与MySQL相同，输出为base64:type<列类型>:<base64>
+	this.text.WriteString(fmt.Sprintf(`"base64:type%d:%s"`, columnType, base64.StdEncoding.EncodeToString(data)))
+	return append([]byte{}, data...), nil
+}
+
+// 小对象、数组中，2字节以内的值直接放在值的偏移处；大对象、数组中是4字节以内
+func isJSONInlined(valueType byte, large bool) bool {
+	switch valueType {
+	case JSONB_TYPE_LITERAL, JSONB_TYPE_INT16, JSONB_TYPE_UINT16:
+		return true
+	case JSONB_TYPE_INT32, JSONB_TYPE_UINT32:
+		return large
+	}
+	return false
+}
+
+func readJSONOffset(data []byte, pos int, large bool) int {
+	if large {
+		return int(binary.LittleEndian.Uint32(data[pos:]))
+	}
+	return int(binary.LittleEndian.Uint16(data[pos:]))
+}
+
+// 变长的长度(每个字节低7位，最高位为1表示后面还有，最多5个字节)，然后是这么多字节的数据
+func readJSONVariableBytes(data []byte) ([]byte, error) {
+	length := 0
+	for i := 0; i < 5 && i < len(data); i++ {
+		length |= int(data[i]&0x7f) << (7 * uint(i))
+		if data[i]&0x80 == 0 {
+			if i+1+length > len(data) {
+				return nil, JSONDecodeError{fmt.Sprintf("length %d is out of range", length)}
+			}
+			return data[i+1 : i+1+length], nil
+		}
+	}
+	return nil, JSONDecodeError{"variable length is invalid"}
+}
+
+// 与MySQL相同：整数值的浮点数后面加.0，指数不带+号，如1.0、1e20
+func formatJSONDouble(f float64) string {
+	ret := strconv.FormatFloat(f, 'g', -1, 64)
+	ret = strings.Replace(ret, "e+", "e", 1)
+	if !strings.ContainsAny(ret, ".eIN") {
+		ret += ".0"
+	}
+	return ret
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(fmt.Sprintf(`\u%04x`, r))
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
@@ -0,0 +1,214 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 二进制JSON中的一个值：类型和数据
+type testJSONValue struct {
+	valueType byte
+	data      []byte
+}
+
+func (this testJSONValue) bytes() []byte {
+	return append([]byte{this.valueType}, this.data...)
+}
+
+func testJSONLiteral(literal byte) testJSONValue {
+	return testJSONValue{JSONB_TYPE_LITERAL, []byte{literal}}
+}
+func testJSONInt16(v int16) testJSONValue {
+	return testJSONValue{JSONB_TYPE_INT16, binary.LittleEndian.AppendUint16(nil, uint16(v))}
+}
+func testJSONString(s string) testJSONValue {
+	data := []byte{}
+	for n := len(s); ; n >>= 7 {
+		if n < 0x80 {
+			data = append(data, byte(n))
+			break
+		}
+		data = append(data, byte(n&0x7f)|0x80)
+	}
+	return testJSONValue{JSONB_TYPE_STRING, append(data, s...)}
+}
+
+// 按MySQL的格式编码对象或数组，keys为nil时是数组
+func testJSONComposite(large bool, keys []string, values []testJSONValue) testJSONValue {
+	offsetSize := 2
+	valueType := JSONB_TYPE_SMALL_ARRAY
+	if keys != nil {
+		valueType = JSONB_TYPE_SMALL_OBJECT
+	}
+	if large {
+		offsetSize = 4
+		valueType++
+	}
+	appendOffset := func(buf []byte, offset int) []byte {
+		if large {
+			return binary.LittleEndian.AppendUint32(buf, uint32(offset))
+		}
+		return binary.LittleEndian.AppendUint16(buf, uint16(offset))
+	}
+	pos := 2*offsetSize + len(keys)*(offsetSize+2) + len(values)*(1+offsetSize)
+	entries := []byte{}
+	body := []byte{}
+	for _, key := range keys {
+		entries = appendOffset(entries, pos)
+		entries = binary.LittleEndian.AppendUint16(entries, uint16(len(key)))
+		body = append(body, key...)
+		pos += len(key)
+	}
+	for _, value := range values {
+		entries = append(entries, value.valueType)
+		if isJSONInlined(value.valueType, large) {
+			entries = append(entries, value.data...)
+			entries = append(entries, make([]byte, offsetSize-len(value.data))...)
+		} else {
+			entries = appendOffset(entries, pos)
+			body = append(body, value.data...)
+			pos += len(value.data)
+		}
+	}
+	data := appendOffset(appendOffset(nil, len(values)), pos)
+	data = append(data, entries...)
+	return testJSONValue{valueType, append(data, body...)}
+}
+
+func testJSONOpaque(columnType ColumnType, data []byte) testJSONValue {
+	return testJSONValue{JSONB_TYPE_OPAQUE, append([]byte{byte(columnType), byte(len(data))}, data...)}
+}
+
+func TestJSONBinaryScalars(t *testing.T) {
+	longString := strings.Repeat("x", 200)
+	tests := []struct {
+		value    testJSONValue
+		expected interface{}
+		text     string
+	}{
+		{testJSONLiteral(JSONB_NULL_LITERAL), nil, "null"},
+		{testJSONLiteral(JSONB_TRUE_LITERAL), true, "true"},
+		{testJSONLiteral(JSONB_FALSE_LITERAL), false, "false"},
+		{testJSONInt16(-2), int64(-2), "-2"},
+		{testJSONValue{JSONB_TYPE_UINT16, []byte{0xff, 0xff}}, uint64(65535), "65535"},
+		{testJSONValue{JSONB_TYPE_INT32, []byte{0xff, 0xff, 0xff, 0xff}}, int64(-1), "-1"},
+		{testJSONValue{JSONB_TYPE_UINT32, []byte{0xff, 0xff, 0xff, 0xff}}, uint64(math.MaxUint32), "4294967295"},
+		{testJSONValue{JSONB_TYPE_INT64, binary.LittleEndian.AppendUint64(nil, 1<<63)}, int64(math.MinInt64), "-9223372036854775808"},
+		{testJSONValue{JSONB_TYPE_UINT64, binary.LittleEndian.AppendUint64(nil, math.MaxUint64)}, uint64(math.MaxUint64), "18446744073709551615"},
+		{testJSONValue{JSONB_TYPE_DOUBLE, binary.LittleEndian.AppendUint64(nil, math.Float64bits(1.5))}, 1.5, "1.5"},
+		{testJSONValue{JSONB_TYPE_DOUBLE, binary.LittleEndian.AppendUint64(nil, math.Float64bits(100))}, 100.0, "100.0"},
+		{testJSONValue{JSONB_TYPE_DOUBLE, binary.LittleEndian.AppendUint64(nil, math.Float64bits(1e20))}, 1e20, "1e20"},
+		{testJSONString("a\"b\\\n\x01中"), "a\"b\\\n\x01中", `"a\"b\\\n\u0001中"`},
+		// 长度超过127时用2个字节表示
+		{testJSONString(longString), longString, `"` + longString + `"`},
+	}
+	for _, test := range tests {
+		ret, err := decodeJSONBinary(test.value.bytes())
+		if err != nil {
+			t.Error(test.text, ":", err)
+			continue
+		}
+		if ret.Value != test.expected || ret.String() != test.text {
+			t.Errorf("value=%#v text=%v expected=%#v %v", ret.Value, ret, test.expected, test.text)
+		}
+	}
+
+	// 空的是null
+	if ret, err := decodeJSONBinary(nil); err != nil || ret.Value != nil || ret.String() != "null" {
+		t.Error("empty=", ret, err)
+	}
+}
+
+func TestJSONBinaryComposite(t *testing.T) {
+	expected := map[string]interface{}{
+		"a":   int64(1),
+		"b":   []interface{}{true, nil, "x", int64(-300)},
+		"abc": map[string]interface{}{},
+	}
+	text := `{"a": 1, "b": [true, null, "x", -300], "abc": {}}`
+	for _, large := range []bool{false, true} {
+		array := testJSONComposite(large, nil, []testJSONValue{
+			testJSONLiteral(JSONB_TRUE_LITERAL), testJSONLiteral(JSONB_NULL_LITERAL), testJSONString("x"), testJSONInt16(-300),
+		})
+		// 键按长度、再按字节排序
+		object := testJSONComposite(large, []string{"a", "b", "abc"}, []testJSONValue{
+			testJSONInt16(1), array, testJSONComposite(false, []string{}, nil),
+		})
+		ret, err := decodeJSONBinary(object.bytes())
+		if err != nil {
+			t.Fatal("large=", large, err)
+		}
+		if !reflect.DeepEqual(ret.Value, expected) || ret.String() != text {
+			t.Errorf("large=%v value=%#v text=%v", large, ret.Value, ret)
+		}
+	}
+
+	// 大的数组中int32直接放在偏移的位置
+	array := testJSONComposite(true, nil, []testJSONValue{{JSONB_TYPE_INT32, []byte{0x00, 0x00, 0x01, 0x00}}})
+	if ret, err := decodeJSONBinary(array.bytes()); err != nil || ret.String() != "[65536]" {
+		t.Error("large array=", ret, err)
+	}
+
+	// 值的偏移超出范围：元素个数、大小、键的偏移和长度之后是值的类型和偏移
+	object := testJSONComposite(false, []string{"a"}, []testJSONValue{testJSONString("x")})
+	object.data[9] = 0xff
+	if _, err := decodeJSONBinary(object.bytes()); err == nil {
+		t.Error("invalid object decoded")
+	}
+	if _, err := decodeJSONBinary(object.bytes()[:6]); err == nil {
+		t.Error("truncated object decoded")
+	}
+}
+
+func TestJSONBinaryOpaque(t *testing.T) {
+	// 2020-01-02 03:04:05.123456
+	ymd := int64((2020*13+1)<<5 | 2)
+	hms := int64(3<<12 | 4<<6 | 5)
+	datetime := binary.LittleEndian.AppendUint64(nil, uint64((ymd<<17|hms)<<24|123456))
+	ret, err := decodeJSONBinary(testJSONOpaque(ColumnTypeDatetime, datetime).bytes())
+	if err != nil || ret.String() != `"2020-01-02 03:04:05.123456"` {
+		t.Error("datetime=", ret, err)
+	}
+	if value, ok := ret.Value.(ColumnValueTimeType); !ok || value.String() != "2020-01-02 03:04:05.123456000" {
+		t.Errorf("datetime=%#v", ret.Value)
+	}
+	date := binary.LittleEndian.AppendUint64(nil, uint64(ymd<<17<<24))
+	if ret, err = decodeJSONBinary(testJSONOpaque(ColumnTypeDate, date).bytes()); err != nil || ret.String() != `"2020-01-02"` {
+		t.Error("date=", ret, err)
+	}
+
+	// -01:02:03.500000
+	packed := -int64((1<<12|2<<6|3)<<24 | 500000)
+	ret, err = decodeJSONBinary(testJSONOpaque(ColumnTypeTime, binary.LittleEndian.AppendUint64(nil, uint64(packed))).bytes())
+	if err != nil || ret.String() != `"-01:02:03.500000"` || ret.Value != -(time.Hour+2*time.Minute+3500*time.Millisecond) {
+		t.Error("time=", ret, err)
+	}
+
+	// DECIMAL(3,2)的1.25
+	if ret, err = decodeJSONBinary(testJSONOpaque(ColumnTypeNewDecimal, []byte{3, 2, 0x81, 0x19}).bytes()); err != nil || ret.String() != "1.25" || ret.Value != "1.25" {
+		t.Error("decimal=", ret, err)
+	}
+
+	// 其它类型输出base64
+	ret, err = decodeJSONBinary(testJSONOpaque(ColumnTypeVarchar, []byte("abc")).bytes())
+	if err != nil || ret.String() != `"base64:type15:YWJj"` || !reflect.DeepEqual(ret.Value, []byte("abc")) {
+		t.Error("opaque=", ret, err)
+	}
+}
+
+func TestJSONColumnValue(t *testing.T) {
+	object := testJSONComposite(false, []string{"k"}, []testJSONValue{testJSONString("v")}).bytes()
+	rows := []byte{0x11, 0, 0, 0, 0, 0, 0x01, 0x00, 0x02, 0x00, 1, 0x01, 0x00}
+	rows = binary.LittleEndian.AppendUint32(rows, uint32(len(object)))
+	rows = append(rows, object...)
+	_, events := readTestEvents(t, testTableMapEventWith([]ColumnType{ColumnTypeJSON}, []byte{4}, nil), testEventPacket(EventTypeWriteRowsEventv2, rows))
+	value := events[1].(RowsEventType).Rows[0].Value1[0]
+	json, ok := value.GetJSON()
+	if !ok || json.String() != `{"k": "v"}` || !reflect.DeepEqual(json.Value, map[string]interface{}{"k": "v"}) {
+		t.Error("json=", value)
+	}
+}
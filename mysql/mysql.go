@@ -1,748 +1,1696 @@
-package mysql
-
-import (
-	"bytes"
-	"fmt"
-	"io"
-	"net"
-	"reflect"
-	"strconv"
-	"strings"
-	"time"
-)
-
-type MysqlStream struct {
-	Stream
-	conn net.Conn
-}
-
-func NewMysqlStream(conn net.Conn) *MysqlStream {
-	this := &MysqlStream{}
-	this.Stream.initStream()
-	this.conn = conn
-	go func() {
-		// 从readChannel读server发来的数据
-		for {
-			select {
-			case n, ok := <-this.controlChannel:
-				if !ok {
-					// 被stream关闭，表示主动终止
-					return
-				}
-				// 读网络
-				buf := make([]byte, n)
-				conn.SetReadDeadline(time.Now().Add(time.Second * 3))
-				//n, err := conn.Read(buf)
-				intn, err := io.ReadFull(conn, buf)
-				n = int64(intn) // 如果int是32位的，在读入>4G数据时会有问题
-				// 写给stream
-				bs := byteStream{}
-				bs.bytes = buf
-				bs.n = n
-				bs.err = err
-				// Dial.Read如果服务器主动关闭，返回的err=EOF
-				// Dial.Read如果超时没有数据，返回的err=read tcp 10.4.12.79:50920->10.21.200.75:3306: i/o timeout
-				this.readChannel <- bs
-				if err != nil {
-					close(this.readChannel)
-					this.readChannel = nil
-				}
-
-			case bytes, ok := <-this.writeChannel:
-				if ok {
-					// 如果写入通道关闭，表示只从mysql读
-					conn.SetWriteDeadline(time.Now().Add(time.Second * 3))
-					n, err := conn.Write(bytes)
-					wr := writeResult{}
-					wr.n = n
-					wr.err = err
-					this.writeResultChannel <- wr
-
-				}
-			}
-		}
-	}()
-	return this
-}
-
-func (this *MysqlStream) readInTimeout() ([]byte, int, error) {
-	deadline := time.Now().Add(time.Second * 120)
-	this.conn.SetReadDeadline(deadline)
-	this.conn.SetWriteDeadline(deadline)
-	bytes, len, err := this.readNBytes(1024)
-	return bytes, len, err
-}
-
-type ColumnAttr struct {
-	Name          string   // 列名
-	Type          string   // 列类型
-	SetTypeValues []string // 如果某列的类型是SET，后面是值的列表
-	Signed        bool     // 数值型字段是有符号(false)还是无符号(true)
-}
-
-func NewColumnAttr(col *TableColumn) ColumnAttr {
-	columnAttr := ColumnAttr{}
-	columnAttr.Name = col.Name
-	columnAttr.Type = col.ColumnType
-	if col.ColumnType == "set" || col.ColumnType == "enum" {
-		columnAttr.SetTypeValues = col.SetParams
-	}
-	columnAttr.Signed = col.Unsigned
-	return columnAttr
-}
-
-type TableAttr []ColumnAttr
-type TableAttrs map[string]TableAttr  // 表名=>表属性
-type SchemaAttr map[string]TableAttrs // 数据库名=>各表属性
-type TableMapsType map[Uint8]TableMapEventType
-
-type ServerConfigType struct {
-	Version               string
-	CapabilityFlags       Uint4         // 服务器的特征标志位。一般是服务器决定的(32位)
-	StatusFlags           Uint2         // 服务器传来的状态
-	BinlogVersion         int           // binlog版本，一般=4
-	EventTypeHeaderLength []byte        // event头部长度
-	TableMaps             TableMapsType // 各个表的结构
-	Columns               SchemaAttr    // 库名=>表名=>列名=>属性
-	ServerCrc32CheckFlag  bool          // CRC校验标记。从mysql 5.6.0开始支持这个功能。之后为true，之前为false
-	CrcSize               int           // CRC用到的长度
-	BinlogFilename        string        // 当前读到的binlog文件名
-	BinlogPosition        uint32        // 正要读的下一个binlog的位置
-}
-
-func NewServerConfig() *ServerConfigType {
-	ret := &ServerConfigType{}
-	ret.TableMaps = make(TableMapsType)
-	ret.Columns = make(SchemaAttr)
-	ret.CrcSize = 0
-	return ret
-}
-func (this *ServerConfigType) getColumn(schema, table, column string) *ColumnAttr {
-	if schemaAttr, ok := this.Columns[schema]; ok {
-		if tableAttr, ok := schemaAttr[table]; ok {
-			for _, columnAttr := range tableAttr {
-				if columnAttr.Name == column {
-					return &columnAttr
-				}
-			}
-		}
-	}
-	return nil
-}
-func (this *ServerConfigType) getColumnAt(schema, table string, idx int) *ColumnAttr {
-	if schemaAttr, ok := this.Columns[schema]; ok {
-		if tableAttr, ok := schemaAttr[table]; ok {
-			if idx < len(tableAttr) {
-				return &tableAttr[idx]
-			}
-		}
-	}
-	return nil
-}
-func (this *ServerConfigType) compareVersion(ver string) int {
-	serverVerionSubstrings := strings.Split(this.Version, ".")
-	ver2Substrings := strings.Split(ver, ".")
-
-	serverVerionInts := make([]int64, 0)
-	ver2Ints := make([]int64, 0)
-	for _, b := range serverVerionSubstrings {
-		b = strings.TrimFunc(b, reserveNumFunc)
-		i, _ := strconv.ParseInt(b, 0, 64)
-		serverVerionInts = append(serverVerionInts, i)
-	}
-	for _, b := range ver2Substrings {
-		b = strings.TrimFunc(b, reserveNumFunc)
-		i, _ := strconv.ParseInt(b, 0, 64)
-		ver2Ints = append(ver2Ints, i)
-	}
-
-	var minLen int
-	if len(serverVerionSubstrings) < len(ver2Substrings) {
-		minLen = len(serverVerionSubstrings)
-	} else {
-		minLen = len(ver2Substrings)
-	}
-	for i := 0; i < minLen; i++ {
-		if serverVerionInts[i] < ver2Ints[i] {
-			return -1
-		} else if serverVerionInts[i] > ver2Ints[i] {
-			return +1
-		}
-	}
-	return 0
-}
-
-// MySql命令状态机：
-const (
-	UNCONNECTED = iota // 未连接到服务器
-	CONNECTED          // TCP连接完成
-	HANDSHAKED         // 握手完成
-)
-
-type MysqlServer struct {
-	config               Config
-	storage              Storage
-	log                  Log
-	stream               *MysqlStream
-	state                int
-	authenticationMethod AuthenticationMethodType
-	serverConfig         *ServerConfigType
-}
-
-type MysqlErrorCodeType int
-
-const (
-	NOT_CONNECTED MysqlErrorCodeType = iota + 1
-	CONNECTING_FAILED
-	NOT_HANDSHAKED
-	MYSQL_ERROR         // Mysql返回了ErrPacket，导致后续无法进行
-	NOT_EXPECTED_PACKET // 返回了一个意料之外的回返包，可能是mysql新版不支持等原因
-)
-
-type MysqlError struct {
-	Code   MysqlErrorCodeType
-	server *MysqlServer
-	err    error
-}
-
-func (this MysqlError) Error() string {
-	var ret string
-	switch this.Code {
-	case NOT_CONNECTED:
-		ret = "Not Connected"
-	case CONNECTING_FAILED:
-		ret = fmt.Sprintf("Connecting failed for %s", this.err.Error())
-	case MYSQL_ERROR:
-		ret = fmt.Sprintf("Mysql returned an error %s", this.err.Error())
-	default:
-		ret = fmt.Sprintf("MysqlError:%v", this.Code)
-	}
-	ret = fmt.Sprintf("Server=%s:%d %s", this.server.config.Host, this.server.config.Port, ret)
-	return ret
-}
-
-func NewMysqlServer(config Config, storage Storage, log Log) *MysqlServer {
-	this := &MysqlServer{}
-	this.config = config
-	this.storage = storage
-	this.log = log
-	this.state = UNCONNECTED
-	this.serverConfig = NewServerConfig()
-	return this
-}
-
-// func Test() {
-// 	// S->C  4e 00 00 00 0a 35 2e 35 2e 36 32 2d 6c 6f 67 00  47 00 00 00 32 47 52 43 3c 41 73 3e 00 ff f7 08    N....5.5.62-log. G...2GRC<As>....
-// 	//       02 00 0f 80 15 00 00 00 00 00 00 00 00 00 00 7a  6d 35 5d 58 7e 34 28 66 4d 2c 36 00 6d 79 73 71    ...............z m5]X~4(fM,6.mysq
-// 	//       6c 5f 6e 61 74 69 76 65 5f 70 61 73 73 77 6f 72  64 00                                              l_native_passwor d.
-// 	// 32 47 52 43 3c 41 73 3e
-// 	// flags = low=ff f7 high=0f 80, CLIENT_PLUGIN_AUTH=1, Length of auth-plugin-data=0x15, CLIENT_SECURE_CONNECTION=1,
-// 	// auth-plugin-data-part-1 = 32 47 52 43 3c 41 73 3e
-// 	// len of auth-plugin-data-part-2 = max(13, 0x15-8) = 13, auth-plugin-data-part-2 = 7a  6d 35 5d 58 7e 34 28 66 4d 2c 36 00
-// 	// C->S  55 00 00 01 85 a6 7f 00 00 00 00 01 21 00 00 00  00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00    U...........!... ................
-// 	//       00 00 00 00 74 65 73 74 5f 75 73 65 72 00 14 b1  dc 18 f5 10 f1 c9 9f 79 8f 3e 46 34 74 40 91 bd    ....test_user... .......y.>F4t@..
-// 	//       a3 b4 37 6d 79 73 71 6c 5f 6e 61 74 69 76 65 5f  70 61 73 73 77 6f 72 64 00                         ..7mysql_native_ password.
-// 	// flags = 85 a6 7f 00, LENENC_CLIENT_DATA = 1, length of auth-response=0x14, auth response=b1  dc 18 f5 10 f1 c9 9f 79 8f 3e 46 34 74 40 91 bd a3 b4 37
-// 	buf := []byte{0x32, 0x47, 0x52, 0x43, 0x3c, 0x41, 0x73, 0x3e, 0x7a, 0x6d, 0x35, 0x5d, 0x58, 0x7e, 0x34, 0x28, 0x66, 0x4d, 0x2c, 0x36}
-// 	key := string(buf)
-// 	fmt.Println("key=", key, " len=", len(key))
-// 	r := nativeAuth("123456", key)
-// 	x := []byte(r)
-// 	for t := range x {
-// 		fmt.Println(fmt.Sprintf("%2x", x[t]))
-// 	}
-// }
-
-func (this *MysqlServer) Log(tag uint32, content string) {
-	if tag&this.config.LogTag != 0 {
-		this.log.Log(tag, content)
-	}
-}
-func (this *MysqlServer) LogError(err error) error {
-	this.Log(LogError, err.Error())
-	return err
-}
-func (this *MysqlServer) Connect() error {
-	// 设置超时时间等
-	conn, err := net.Dial("tcp", fmt.Sprintf("%v:%v", this.config.Host, this.config.Port))
-	if err != nil {
-		return MysqlError{CONNECTING_FAILED, this, err}
-	}
-	this.stream = NewMysqlStream(conn)
-	this.stream.log = this.log
-	this.state = CONNECTED
-	this.stream.serverConfig = this.serverConfig
-	return nil
-}
-
-type RowHistory struct {
-	NewValues []ColumnValueType
-	Values    []ColumnValueType
-}
-
-func (this RowHistory) String() string {
-	buf := bytes.NewBufferString("{Type:RowHistory, NewValues:[")
-	for _, v := range this.NewValues {
-		buf.WriteString(v.String())
-		buf.WriteString(",")
-	}
-	buf.WriteString("], Values:[")
-	for _, v := range this.Values {
-		buf.WriteString(v.String())
-		buf.WriteString(",")
-	}
-	buf.WriteString("]}")
-	return buf.String()
-}
-
-type DataHistory struct {
-	// 发生的时间
-	Schema string
-	Table  string
-	Rows   []RowHistory
-}
-
-func (this DataHistory) String() string {
-	buf := bytes.NewBufferString("{Type:DataHistory, Rows:[")
-	for _, v := range this.Rows {
-		buf.WriteString(v.String())
-		buf.WriteString(",")
-	}
-	buf.WriteString("]}")
-	return buf.String()
-}
-func NewDataHistory(columnValue RowsEventType, tableMap *TableMapEventType) DataHistory {
-	ret := DataHistory{}
-	if tableMap != nil {
-		ret.Schema = string(tableMap.SchemaName)
-		ret.Table = string(tableMap.TableName)
-	}
-	for _, row := range columnValue.Rows {
-		rowHistory := RowHistory{}
-		rowHistory.NewValues = row.Value2
-		rowHistory.Values = row.Value1
-		ret.Rows = append(ret.Rows, rowHistory)
-	}
-	return ret
-}
-
-type CallbackInterface interface {
-	// 执行SQL
-	OnQuery(sql string)
-	// 插入、更新、删除
-	OnInsert(row DataHistory)
-	OnUpdate(row DataHistory)
-	OnDelete(row DataHistory)
-	// 当无事件时调用。如果返回true表示结束。如果返回false会继续等新的事件
-	OnEnd() bool
-	// 当缺少表结构时调用
-	OnColumnAttr(schema, table string, colIdx int)
-	// OnError()
-}
-
-func (this *MysqlServer) Open() error {
-	if this.state != CONNECTED {
-		return MysqlError{NOT_CONNECTED, this, nil}
-	}
-	err := this.handshake(this.config.User, this.config.Pass, "")
-	if err != nil {
-		return err
-	}
-	this.state = HANDSHAKED
-	return nil
-}
-func (this *MysqlServer) Close() error {
-	return nil
-}
-func (this *MysqlServer) Replicate(callback CallbackInterface) error {
-	if this.state != HANDSHAKED {
-		return MysqlError{NOT_HANDSHAKED, this, nil}
-	}
-	if this.serverConfig.compareVersion("5.6.2") >= 0 {
-		// 要导出binlog，需要先关闭binlog checksum
-		com := NewComQuery("SET @master_binlog_checksum='NONE'")
-		writeResultRet := this.stream.WriteCom(com)
-		if writeResultRet.err != nil {
-			return writeResultRet.err
-		}
-		comResponse, err := this.stream.Read()
-		if err != nil {
-			return err
-		}
-		if _, ok := comResponse.(OKPacket); ok {
-		} else if errPacket, ok := comResponse.(ErrPacket); ok {
-			return this.errorByErrPacket(errPacket)
-		} else {
-			return this.errorNotExpectedPacket(comResponse)
-		}
-	}
-
-	// 把自己注册成一个Slave
-	registerCom := NewComRegisterSlave()
-	writeResultRet := this.stream.WriteCom(registerCom)
-	if writeResultRet.err != nil {
-		return writeResultRet.err
-	}
-	reisterComResponse, err := this.stream.Read()
-	if err != nil {
-		return err
-	}
-	if errPacket, ok := reisterComResponse.(ErrPacket); ok {
-		return this.errorByErrPacket(errPacket)
-	}
-	if _, ok := reisterComResponse.(OKPacket); !ok {
-		return this.errorNotExpectedPacket(reisterComResponse)
-	}
-
-	// DUMP表
-	// Mysql 5.5.62不支持，会报ErrPacket {Type:ErrPacket, Header:255, ErrorCode:1047, SqlStateMarker:, SqlState:, ErrorMessage:#08S01Unknown command,
-	//dumpTableCom := NewComTableDump()
-	//writeResultRet = this.stream.WriteCom(dumpTableCom)
-	//if writeResultRet.err != nil{
-	//return writeResultRet.err
-	//}
-
-	var filename string
-	var binlogPos uint32
-	if this.config.Continue {
-		if this.storage != nil {
-			// 读
-			filename = this.serverConfig.BinlogFilename
-			binlogPos = this.serverConfig.BinlogPosition
-		}
-	}
-	// var jump uint32
-	// 如果读不到，或者continue为false
-	if filename == "" || !this.config.Continue {
-		switch this.config.DumpFrom {
-		case DumpFromBeginning:
-			filename = "mysql-bin.000001"
-			binlogPos = 4
-		case DumpFromLatest:
-			// TODO: 再连接，show master status，取得当前位置
-			// filename = "xxx"
-			// binlogPos = xxx
-			fallthrough
-		case DumpFromPosition:
-			// 后两种情况特殊，不能从指定的位置开始。因为binglog第一条是format_description_event
-			// 如果不读这个记录，后面会报错。所以只能从第1条开始读，然后前面的跳过
-			filename = this.config.BinlogPosition.Filename
-			binlogPos = this.config.BinlogPosition.BinlogPos
-		}
-		// 这里后两种需要判断一下，如果当前记下来的format_description_event不是指定文件的，需要从头开始读，再跳过
-		if filename != this.serverConfig.BinlogFilename {
-			// jump = binlogPos
-			binlogPos = 4
-		}
-	}
-
-	dumpBinlogCom := NewComBinlogDump(this.config.ServerId, filename, binlogPos)
-
-	// 非阻塞
-	dumpBinlogCom.Flags = 0x01
-
-	writeResultRet = this.stream.WriteCom(dumpBinlogCom)
-	if writeResultRet.err != nil {
-		return writeResultRet.err
-	}
-
-	for {
-		pkt, err := this.stream.ReadEvent()
-		if err == nil {
-			// TOOD: 当前binlog的位置？与jump比较
-			//var eventHeader EventHeaderType
-			if fullEvent, ok := pkt.(FullEvent); ok {
-				pkt = fullEvent.event
-			}
-			if _, ok := pkt.(EOFPacket); ok {
-				if callback.OnEnd() {
-					return nil
-				}
-			} else if formatDescriptionEvent, ok := pkt.(FormatDescriptionEventType); ok {
-				this.serverConfig.EventTypeHeaderLength = formatDescriptionEvent.EventTypeHeaderLength
-				// this.serverConfig.BinlogFilename = xxx
-				// this.serverConfig.BinlogFilename = xxx
-			} else if tableMapEvent, ok := pkt.(TableMapEventType); ok {
-				this.serverConfig.TableMaps[Uint8(tableMapEvent.TableId)] = tableMapEvent
-			} else if rowsEvent, ok := pkt.(RowsEventType); ok {
-				var tableMap *TableMapEventType
-				if tm, ok := this.serverConfig.TableMaps[Uint8(rowsEvent.TableId)]; ok {
-					tableMap = &tm
-				}
-				row := NewDataHistory(rowsEvent, tableMap)
-				//fmt.Println("ROW=", row)
-				if _, ok := this.serverConfig.TableMaps[Uint8(rowsEvent.TableId)]; ok {
-					// 这里解析成我们需要的数据，包括列名、正负等
-				} else {
-					// 这个表的结构未知
-				}
-				switch rowsEvent.Command {
-				case RowsEvenCommandInsert:
-					callback.OnInsert(row)
-				case RowsEvenCommandUpdate:
-					callback.OnUpdate(row)
-				case RowsEvenCommandDelete:
-					callback.OnDelete(row)
-				}
-			} else if queryEvent, ok := pkt.(QueryEventType); ok {
-				//fmt.Println("queryEvent=", queryEvent)
-				schema := string(queryEvent.Schema)
-				var tableAsts []*Table
-				tableAsts, err = parseSql(string(queryEvent.Query))
-				if err != nil {
-					// sql 解析失败，有可能是一些无法识别的类型导致的。比如 ruiaylin/sqlparser 不支持geometry类型，也可能是合法的语句如BEGIN等。这种情况只能先跳过了
-					//fmt.Println("parseSql err=", err)
-				} else {
-					//fmt.Println("tableAsts=", tableAsts)
-					for _, tableAst := range tableAsts {
-						// queryEvent.Schema 有时是“”，空字符串。怀疑是mysql的bug(5.5.62)。当这里是空字符串时，用下面sql解析出来的schema
-						// Q_UPDATED_DB_NAMES 表示当前DB？
-						if schema == "" {
-							schema = tableAst.Schema
-						}
-						if schema != tableAst.Schema && schema == "" && tableAst.Schema != "" {
-							// binlog中的schema与解析出来的不相符？
-							panic("binlog schema != tableAstSchema, binlog schema=" + schema + " tableAst.schema=" + tableAst.Schema)
-						}
-
-						if _, ok := this.serverConfig.Columns[schema]; !ok {
-							this.serverConfig.Columns[schema] = make(TableAttrs)
-						}
-						if _, ok := this.serverConfig.Columns[schema][string(tableAst.Name)]; !ok {
-							this.serverConfig.Columns[schema][string(tableAst.Name)] = make(TableAttr, 0)
-						}
-
-						//fmt.Println("Action=", tableAst.Action, " Schema=", tableAst.Schema, " Table=", tableAst.Name)
-						for _, col := range tableAst.Cols {
-							// fmt.Println("col.Name=", col.Name)
-							// fmt.Println("col.ColumnType=", col.ColumnType)
-							// fmt.Println("col.Unsigned=", col.Unsigned)
-							// fmt.Println("col.SetParams=", col.SetParams)
-							// fmt.Println("col.Position=", col.Position)
-							// fmt.Println("col.AddAfter=", col.AddAfter)
-							// fmt.Println("col.Drop=", col.Drop)
-
-							switch tableAst.Action {
-							case ActionCreate:
-								columnAttr := NewColumnAttr(col)
-								this.serverConfig.Columns[schema][tableAst.Name] = append(this.serverConfig.Columns[schema][tableAst.Name], columnAttr)
-
-							case ActionAlter:
-								//fmt.Println("ActionAlter=", queryEvent.Query)
-								if col.Drop {
-									// 删除一列
-									colIdx := -1
-									for idx, colFound := range this.serverConfig.Columns[schema][tableAst.Name] {
-										if colFound.Name == col.Name {
-											colIdx = idx
-											break
-										}
-									}
-									if colIdx != -1 {
-										l := len(this.serverConfig.Columns[schema][tableAst.Name])
-										if colIdx == 0 {
-											this.serverConfig.Columns[schema][tableAst.Name] = this.serverConfig.Columns[schema][tableAst.Name][1:]
-										} else if colIdx == l-1 {
-											this.serverConfig.Columns[schema][tableAst.Name] = this.serverConfig.Columns[schema][tableAst.Name][:l-1]
-										} else {
-											tmp := this.serverConfig.Columns[schema][tableAst.Name][0:colIdx]
-											tmp = append(tmp, this.serverConfig.Columns[schema][tableAst.Name][colIdx+1:]...)
-											this.serverConfig.Columns[schema][tableAst.Name] = tmp
-										}
-									}
-								} else {
-									// 新增一列
-									columnAttr := NewColumnAttr(col)
-									if col.Position == AddColumnAtTail {
-										this.serverConfig.Columns[schema][tableAst.Name] = append(this.serverConfig.Columns[schema][tableAst.Name], columnAttr)
-									} else if col.Position == AddColumnAtFirst {
-										tmp := make([]ColumnAttr, 0)
-										tmp = append(tmp, columnAttr)
-										this.serverConfig.Columns[schema][tableAst.Name] = append(tmp, this.serverConfig.Columns[schema][tableAst.Name]...)
-									} else {
-									}
-								}
-							}
-
-						}
-					}
-				}
-				callback.OnQuery(string(queryEvent.Query))
-			}
-			//if _, ok := pkt.(RowsEventType); ok{
-			//	break;
-			//}
-			// 在这里处理给用户的回调
-		} else {
-			fmt.Println(err)
-			break
-		}
-	}
-	return err
-}
-func (this *MysqlServer) errorByErrPacket(errPacket ErrPacket) MysqlError {
-	err := Error{fmt.Sprintf("ErrPacket Code=%d, Msg=%v", errPacket.ErrorCode, errPacket.ErrorMessage), 0}
-	return MysqlError{MYSQL_ERROR, this, err}
-}
-func (this *MysqlServer) errorNotExpectedPacket(packet interface{}) MysqlError {
-	err := Error{fmt.Sprintf("Unexpteced Packet, Type=%v, %v", reflect.TypeOf(packet), packet), 0}
-	return MysqlError{NOT_EXPECTED_PACKET, this, err}
-}
-func (this *MysqlServer) errorMustUse41(packet interface{}) MysqlError {
-	err := Error{fmt.Sprintf("CapabilityFlag_CLIENT_PROTOCOL_41 unsetted, %v", packet), 0}
-	return MysqlError{NOT_EXPECTED_PACKET, this, err}
-}
-func (this *MysqlServer) errorUnknownAuthenticationMethodByAuthSwitchRequest(authMethod string) MysqlError {
-	err := Error{fmt.Sprintf("errorUnknownAuthenticationMethodByAuthSwitchRequest method= %v", authMethod), 0}
-	return MysqlError{NOT_EXPECTED_PACKET, this, err}
-}
-func (this *MysqlServer) errorUnknownAuthenticationMethod(handshakePacket HandshakeV10) MysqlError {
-	err := Error{fmt.Sprintf("Unknown AuthenticationMethod, CLIENT_PROTOCOL_41=%v, CLIENT_SECURE_CONNECTION=%v, CLIENT_PLUGIN_AUTH=%v", CapabilityFlag_CLIENT_PROTOCOL_41.isSet(handshakePacket.CapabilityFlags), CapabilityFlag_CLIENT_SECURE_CONNECTION.isSet(handshakePacket.CapabilityFlags), CapabilityFlag_CLIENT_PLUGIN_AUTH.isSet(handshakePacket.CapabilityFlags)), 0}
-	return MysqlError{NOT_EXPECTED_PACKET, this, err}
-}
-func reserveNumFunc(c rune) bool {
-	var ret bool
-	switch c {
-	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		ret = false
-	default:
-		ret = true
-	}
-	return ret
-}
-func (this *MysqlServer) handshake(username string, password string, database string) error {
-	// Connected -+--------> HandshakePacket -+----> SSLExchange ------> ClientResponse ------> AuthenticationMethodSwitch ------> Disconnect
-	//            |                           |                           ↑        |                     ↓
-	//            | 连接满等                   +---------------------------+        +----------> AuthenticationExchangeContinuation ------> OK
-	//            +--------> ErrPacket                                             |                     |
-	//                                                                             +---------------------+-------------------------------> Err
-	packet, err := this.stream.Read()
-	// this.printPacket(packet)
-	if err != nil {
-		return err
-	}
-	if errPacket, ok := packet.(ErrPacket); ok {
-		return this.errorByErrPacket(errPacket)
-	}
-	if handshakePacket, ok := packet.(HandshakeV10); !ok {
-		return this.errorNotExpectedPacket(packet)
-	} else {
-		// 只支持Client_4.1
-		if Uint4(handshakePacket.CapabilityFlags)&Uint4(CapabilityFlag_CLIENT_PROTOCOL_41) == 0 {
-			return this.errorMustUse41(packet)
-		}
-		this.stream.serverConfig.Version = string(handshakePacket.ServerVersion)
-		// 从5.6.0后开始支持CRC32校验
-		if this.stream.serverConfig.compareVersion("5.6.0") >= 0 {
-			this.serverConfig.ServerCrc32CheckFlag = true
-			this.serverConfig.CrcSize = 4
-		}
-
-		// 判断Authentication Method
-		if !CapabilityFlag_CLIENT_PLUGIN_AUTH.isSet(handshakePacket.CapabilityFlags) {
-			if CapabilityFlag_CLIENT_PROTOCOL_41.isSet(handshakePacket.CapabilityFlags) && CapabilityFlag_CLIENT_SECURE_CONNECTION.isSet(handshakePacket.CapabilityFlags) {
-				this.authenticationMethod = OldPasswordAuthentication
-			} else if CapabilityFlag_CLIENT_PROTOCOL_41.isSet(handshakePacket.CapabilityFlags) && CapabilityFlag_CLIENT_SECURE_CONNECTION.isSet(handshakePacket.CapabilityFlags) {
-				this.authenticationMethod = SecurePasswordAuthentication
-			} else {
-				return this.errorUnknownAuthenticationMethod(handshakePacket)
-			}
-		} else {
-			this.authenticationMethod = AuthenticationMethodType(handshakePacket.AuthPluginName)
-		}
-
-		if f, ok := authMethod[this.authenticationMethod]; ok && f != nil {
-			pluginData := string(handshakePacket.AuthPluginDataPart1 + handshakePacket.AuthPluginDataPart2)
-			authPluginResponseStr := f(this.config.Pass, pluginData)
-			password = string(authPluginResponseStr)
-		} else {
-			return this.errorUnknownAuthenticationMethodByAuthSwitchRequest(string(this.authenticationMethod))
-		}
-		handshakeResponse := NewHandshakeResponse41(&handshakePacket, username, password, database, this.authenticationMethod)
-
-		// 根据文档 dev.mysql.com/doc/internals/en/connection-phase-packets.html ，从5.6.6之后就可以发送变量
-		// 如果不设置这些变量，在replication时会报CRC错误
-		if this.serverConfig.compareVersion("5.6.6") >= 0 {
-			// C->S
-			// bf 00 00 01 05 a2 3e 00 00 00 00 40 08 00 00 00  00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00    ......>....@.... ................
-			// 00 00 00 00 72 65 70 6c 5f 75 73 65 72 00 14 b3  bf 99 85 6f 97 df 88 14 29 10 91 e8 65 74 cc 25    ....repl_user... ...o....)...et.%
-			// cb 80 8a 6d 79 73 71 6c 5f 6e 61 74 69 76 65 5f  70 61 73 73 77 6f 72 64 00 69 03 5f 6f 73 05 4c    ...mysql_native_ password.i._os.L
-			// 69 6e 75 78 0c 5f 63 6c 69 65 6e 74 5f 6e 61 6d  65 08 6c 69 62 6d 79 73 71 6c 04 5f 70 69 64 04    inux._client_nam e.libmysql._pid.
-			// 34 31 33 35 0f 5f 63 6c 69 65 6e 74 5f 76 65 72  73 69 6f 6e 06 35 2e 36 2e 34 36 09 5f 70 6c 61    4135._client_ver sion.5.6.46._pla
-			// 74 66 6f 72 6d 04 69 36 38 36 0c 70 72 6f 67 72  61 6d 5f 6e 61 6d 65 0b 6d 79 73 71 6c 62 69 6e    tform.i686.progr am_name.mysqlbin
-			// 6c 6f 67                                                                                            log
-			handshakeResponse.AddKeyValue("_os", "Linux")
-			handshakeResponse.AddKeyValue("_client_name", "libmysql")
-			handshakeResponse.AddKeyValue("_pid", "4135")
-			handshakeResponse.AddKeyValue("_client_version", strings.TrimFunc(this.stream.serverConfig.Version, reserveNumFunc))
-			handshakeResponse.AddKeyValue("_platform", "i686")
-			handshakeResponse.AddKeyValue("program_name", "mysqlbinlog")
-		}
-		// this.printPacket(handshakeResponse)
-		writeResultRet := this.stream.Write(handshakeResponse)
-		if writeResultRet.err != nil {
-			return writeResultRet.err
-		}
-
-		packet, err = this.stream.Read()
-		// this.printPacket(packet)
-		if errPacket, ok := packet.(ErrPacket); ok {
-			return this.errorByErrPacket(errPacket)
-		} else if _, ok := packet.(OKPacket); ok {
-			goto EXIT
-		}
-
-		// 缺少OKPacket
-		// 切换认证方法（前面是OK或Err就不需要走这里）
-		_, ok1 := packet.(OldAuthSwitchRequest)
-		authSwitchRequest, ok2 := packet.(AuthSwitchRequest)
-		if !ok1 && !ok2 {
-			return this.errorNotExpectedPacket(packet)
-		}
-
-		authSwitchResponse := NewAuthSwitchResponse()
-		var pluginData string
-		if ok1 {
-			this.authenticationMethod = OldPasswordAuthentication
-			pluginData = string(handshakePacket.AuthPluginDataPart1)
-		} else if ok2 {
-			this.authenticationMethod = AuthenticationMethodType(authSwitchRequest.PluginName)
-			pluginData = string(authSwitchRequest.AuthPluginData)
-		}
-
-		if f, ok := authMethod[this.authenticationMethod]; ok && f != nil {
-			authPluginResponseStr := f(this.config.Pass, pluginData)
-			authSwitchResponse.AuthPluginResponse = StringEof(authPluginResponseStr)
-		} else {
-			return this.errorUnknownAuthenticationMethodByAuthSwitchRequest(string(this.authenticationMethod))
-		}
-		// this.printPacket(authSwitchResponse)
-		writeResultRet = this.stream.Write(authSwitchResponse)
-		if writeResultRet.err != nil {
-			return writeResultRet.err
-		}
-
-		// 跳过SSL。这里在不支持
-		packet, err = this.stream.Read()
-		// this.printPacket(packet)
-		if err != nil {
-			return err
-		}
-		if errPacket, ok := packet.(ErrPacket); ok {
-			return this.errorByErrPacket(errPacket)
-		}
-		// OK Packet
-		// 其它Packet
-	}
-EXIT:
-	this.state = HANDSHAKED
-	return nil
-}
-
-// func (this *MysqlServer) printPacket(p interface{}) {
-// 	if str, ok := p.(fmt.Stringer); ok {
-// 		fmt.Println(str)
-// 	} else {
-// 		fmt.Println(p)
-// 	}
-// }
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type MysqlStream struct {
+	Stream
+	conn         net.Conn
+	readTimeout  time.Duration // 每次读网络的超时，0时不限制
+	writeTimeout time.Duration // 每次写网络的超时，0时不限制
+	exited       chan struct{} // 读写网络的goroutine结束时关闭
+}
+
+func NewMysqlStream(conn net.Conn) *MysqlStream {
+	this := &MysqlStream{}
+	this.Stream.initStream()
+	this.conn = conn
+	this.done = make(chan struct{})
+	this.exited = make(chan struct{})
+	go func() {
+		defer close(this.exited)
+		// 从readChannel读server发来的数据
+		// 使用this.conn而不是参数conn，握手时连接可能被升级为TLS
+		for {
+			select {
+			case <-this.done:
+				// 被close关闭
+				return
+			case n, ok := <-this.controlChannel:
+				if !ok {
+					// 被stream关闭，表示主动终止
+					return
+				}
+				// 读网络
+				buf := make([]byte, n)
+				this.conn.SetReadDeadline(deadline(this.readTimeout))
+				//n, err := conn.Read(buf)
+				intn, err := io.ReadFull(this.conn, buf)
+				n = int64(intn) // 如果int是32位的，在读入>4G数据时会有问题
+				// 写给stream
+				bs := byteStream{}
+				bs.bytes = buf
+				bs.n = n
+				bs.err = err
+				// Dial.Read如果服务器主动关闭，返回的err=EOF
+				// Dial.Read如果超时没有数据，返回的err=read tcp 10.4.12.79:50920->10.21.200.75:3306: i/o timeout
+				select {
+				case this.readChannel <- bs:
+				case <-this.done:
+					return
+				}
+				if err != nil {
+					close(this.readChannel)
+					this.readChannel = nil
+				}
+
+			case bytes, ok := <-this.writeChannel:
+				if ok {
+					// 如果写入通道关闭，表示只从mysql读
+					this.conn.SetWriteDeadline(deadline(this.writeTimeout))
+					n, err := this.conn.Write(bytes)
+					wr := writeResult{}
+					wr.n = n
+					wr.err = err
+					select {
+					case this.writeResultChannel <- wr:
+					case <-this.done:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return this
+}
+
+// 关闭连接，并等待读写网络的goroutine结束
+func (this *MysqlStream) close() error {
+	close(this.done)
+	err := this.conn.Close()
+	<-this.exited
+	return err
+}
+
+// 超时对应的deadline。timeout为0时返回零值，表示不限制
+func deadline(timeout time.Duration) time.Time {
+	if timeout > 0 {
+		return time.Now().Add(timeout)
+	}
+	return time.Time{}
+}
+
+type ColumnAttr struct {
+	Name          string   // 列名
+	Type          string   // 列类型
+	SetTypeValues []string // 如果某列的类型是SET，后面是值的列表
+	Unsigned      bool     // 数值型字段是否UNSIGNED
+}
+
+func NewColumnAttr(col *TableColumn) ColumnAttr {
+	columnAttr := ColumnAttr{}
+	columnAttr.Name = col.Name
+	columnAttr.Type = col.ColumnType
+	if col.ColumnType == "set" || col.ColumnType == "enum" {
+		columnAttr.SetTypeValues = col.SetParams
+	}
+	columnAttr.Unsigned = col.Unsigned
+	return columnAttr
+}
+
+type TableAttr []ColumnAttr
+type TableAttrs map[string]TableAttr  // 表名=>表属性
+type SchemaAttr map[string]TableAttrs // 数据库名=>各表属性
+type TableMapsType map[Uint8]TableMapEventType
+
+type ServerConfigType struct {
+	Version               string
+	CapabilityFlags       Uint4         // 服务器的特征标志位。一般是服务器决定的(32位)
+	StatusFlags           Uint2         // 服务器传来的状态
+	BinlogVersion         int           // binlog版本，一般=4
+	EventTypeHeaderLength []byte        // event头部长度
+	TableMaps             TableMapsType // 各个表的结构
+	Columns               SchemaAttr    // 库名=>表名=>列名=>属性
+	ServerCrc32CheckFlag  bool          // CRC校验标记。从mysql 5.6.0开始支持这个功能。之后为true，之前为false
+	CrcSize               int           // CRC用到的长度
+	BinlogFilename        string        // 当前读到的binlog文件名
+	BinlogPosition        uint32        // 正要读的下一个binlog的位置
+	GTIDSet               GTIDSet       // 已经执行完的GTID集合，在事务提交时更新
+}
+
+func NewServerConfig() *ServerConfigType {
+	ret := &ServerConfigType{}
+	ret.TableMaps = make(TableMapsType)
+	ret.Columns = make(SchemaAttr)
+	ret.GTIDSet = NewGTIDSet()
+	ret.CrcSize = 0
+	return ret
+}
+func (this *ServerConfigType) getColumn(schema, table, column string) *ColumnAttr {
+	if schemaAttr, ok := this.Columns[schema]; ok {
+		if tableAttr, ok := schemaAttr[table]; ok {
+			for _, columnAttr := range tableAttr {
+				if columnAttr.Name == column {
+					return &columnAttr
+				}
+			}
+		}
+	}
+	return nil
+}
+func (this *ServerConfigType) getColumnAt(schema, table string, idx int) *ColumnAttr {
+	if schemaAttr, ok := this.Columns[schema]; ok {
+		if tableAttr, ok := schemaAttr[table]; ok {
+			if idx < len(tableAttr) {
+				return &tableAttr[idx]
+			}
+		}
+	}
+	return nil
+}
+
+// 用TABLE_MAP_EVENT的可选元数据更新表结构，它比解析DDL得到的准确，也包括复制开始之前建的表
+// 有列名(binlog_row_metadata=FULL)时替换整个表，只有符号(MINIMAL)时按位置更新已知的列
+func (this *ServerConfigType) applyTableMap(tableMap TableMapEventType) {
+	meta := tableMap.OptionalMetadata
+	schema, table := string(tableMap.SchemaName), string(tableMap.TableName)
+	if meta.ColumnNames != nil {
+		tableAttr := make(TableAttr, len(meta.ColumnNames))
+		for idx := range tableAttr {
+			tableAttr[idx] = tableMap.columnAttr(idx)
+		}
+		if _, ok := this.Columns[schema]; !ok {
+			this.Columns[schema] = make(TableAttrs)
+		}
+		this.Columns[schema][table] = tableAttr
+	} else if meta.Unsigned != nil {
+		if tableAttr := this.Columns[schema][table]; len(tableAttr) == len(meta.Unsigned) {
+			for idx := range tableAttr {
+				tableAttr[idx].Unsigned = meta.Unsigned[idx]
+			}
+		}
+	}
+}
+func (this *ServerConfigType) isMariaDB() bool {
+	return strings.Contains(strings.ToLower(this.Version), "mariadb")
+}
+func (this *ServerConfigType) compareVersion(ver string) int {
+	return compareVersion(this.Version, ver)
+}
+
+// 比较两个版本号，如"5.6.46-log"与"5.6.2"。version大时返回+1
+func compareVersion(version, ver string) int {
+	serverVerionSubstrings := strings.Split(version, ".")
+	ver2Substrings := strings.Split(ver, ".")
+
+	serverVerionInts := make([]int64, 0)
+	ver2Ints := make([]int64, 0)
+	for _, b := range serverVerionSubstrings {
+		b = strings.TrimFunc(b, reserveNumFunc)
+		i, _ := strconv.ParseInt(b, 0, 64)
+		serverVerionInts = append(serverVerionInts, i)
+	}
+	for _, b := range ver2Substrings {
+		b = strings.TrimFunc(b, reserveNumFunc)
+		i, _ := strconv.ParseInt(b, 0, 64)
+		ver2Ints = append(ver2Ints, i)
+	}
+
+	var minLen int
+	if len(serverVerionSubstrings) < len(ver2Substrings) {
+		minLen = len(serverVerionSubstrings)
+	} else {
+		minLen = len(ver2Substrings)
+	}
+	for i := 0; i < minLen; i++ {
+		if serverVerionInts[i] < ver2Ints[i] {
+			return -1
+		} else if serverVerionInts[i] > ver2Ints[i] {
+			return +1
+		}
+	}
+	return 0
+}
+
+// MySql命令状态机：
+const (
+	UNCONNECTED = iota // 未连接到服务器
+	CONNECTED          // TCP连接完成
+	HANDSHAKED         // 握手完成
+)
+
+type MysqlServer struct {
+	config               Config
+	storage              Storage
+	log                  Log
+	stream               *MysqlStream
+	state                int
+	authenticationMethod AuthenticationMethodType
+	compression          CompressionType // 握手时与服务器协商的压缩算法
+	serverConfig         *ServerConfigType
+	committed            *Checkpoint     // 最后一个事务边界的位置(不含Columns)，重连时从这里继续
+	schemaLoader         SchemaLoader    // Config.LoadSchema时查询表结构
+	schemaNotFound       map[string]bool // 查询过但结构仍然不对的表(库名.表名)，DDL之前不再查询
+	columnAttrReported   map[string]bool // 已经回调过OnColumnAttr的表(库名.表名)，DDL之前不再回调
+	schemaLoadRetry      time.Time       // 查询表结构失败后，到这个时间之前不再查询
+	closed               atomic.Bool     // 用户调用了Close，Replicate不再重连
+	mutex                sync.Mutex      // 保护cancel，Close可能在其它goroutine调用
+	cancel               func()          // Replicate进行中时不为nil，Close用它结束Replicate
+}
+
+type MysqlErrorCodeType int
+
+const (
+	NOT_CONNECTED MysqlErrorCodeType = iota + 1
+	CONNECTING_FAILED
+	NOT_HANDSHAKED
+	MYSQL_ERROR         // Mysql返回了ErrPacket，导致后续无法进行
+	NOT_EXPECTED_PACKET // 返回了一个意料之外的回返包，可能是mysql新版不支持等原因
+	BINLOG_DISABLED     // 服务器没有打开binlog
+	OLD_PASSWORD_DENIED // 服务器要求使用mysql_old_password，但Config.AllowOldPasswords没有打开
+)
+
+// ErrPacket中的错误码
+const (
+	ER_MASTER_FATAL_ERROR_READING_BINLOG Uint2 = 1236 // dump时读binlog出错，包括指定的binlog文件已经被purge
+)
+
+type MysqlError struct {
+	Code   MysqlErrorCodeType
+	server *MysqlServer
+	err    error
+}
+
+func (this MysqlError) Error() string {
+	var ret string
+	switch this.Code {
+	case NOT_CONNECTED:
+		ret = "Not Connected"
+	case CONNECTING_FAILED:
+		ret = fmt.Sprintf("Connecting failed for %s", this.err.Error())
+	case MYSQL_ERROR:
+		ret = fmt.Sprintf("Mysql returned an error %s", this.err.Error())
+	case BINLOG_DISABLED:
+		ret = "Binlog is not enabled"
+	case OLD_PASSWORD_DENIED:
+		ret = "mysql_old_password is insecure and not allowed, set Config.AllowOldPasswords to use it"
+	default:
+		ret = fmt.Sprintf("MysqlError:%v", this.Code)
+	}
+	ret = fmt.Sprintf("Server=%s:%v %s", this.server.config.Host, this.server.config.Port, ret)
+	return ret
+}
+
+// 要求的binlog文件已经被purge了，无法从这个位置继续复制
+type BinlogPurgedError struct {
+	Filename  string
+	BinlogPos uint32
+	Message   string // 服务器返回的错误信息
+}
+
+func (this BinlogPurgedError) Error() string {
+	return fmt.Sprintf("Binlog %s:%d has been purged, %s", this.Filename, this.BinlogPos, this.Message)
+}
+
+// 要求使用TLS，但服务器不支持SSL
+type TLSNotSupportedError struct {
+	Host string
+	Port string
+}
+
+func (this TLSNotSupportedError) Error() string {
+	return fmt.Sprintf("Server=%s:%v does not support SSL, but TLS is required", this.Host, this.Port)
+}
+
+func NewMysqlServer(config Config, storage Storage, log Log) *MysqlServer {
+	this := &MysqlServer{}
+	this.config = config
+	this.storage = storage
+	this.log = log
+	this.state = UNCONNECTED
+	this.serverConfig = NewServerConfig()
+	if config.LoadSchema {
+		this.schemaLoader = config.SchemaLoader
+		if this.schemaLoader == nil {
+			this.schemaLoader = NewInformationSchemaLoader(config, log)
+		}
+	}
+	this.schemaNotFound = make(map[string]bool)
+	this.columnAttrReported = make(map[string]bool)
+	return this
+}
+
+func (this *MysqlServer) Log(tag uint32, content string) {
+	if tag&this.config.LogTag != 0 {
+		this.log.Log(tag, content)
+	}
+}
+func (this *MysqlServer) LogError(err error) error {
+	this.Log(LogError, err.Error())
+	return err
+}
+func (this *MysqlServer) Connect() error {
+	return this.ConnectContext(context.Background())
+}
+
+// 与Connect相同。ctx被取消时停止连接，返回ctx.Err()
+func (this *MysqlServer) ConnectContext(ctx context.Context) error {
+	network, address := "tcp", net.JoinHostPort(this.config.Host, this.config.Port)
+	if this.config.Socket != "" {
+		network, address = "unix", this.config.Socket
+	}
+	dialCtx := ctx
+	if this.config.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, this.config.DialTimeout)
+		defer cancel()
+	}
+	dialContext := this.config.DialContext
+	if dialContext == nil {
+		dialer := &net.Dialer{}
+		dialContext = dialer.DialContext
+	}
+	conn, err := dialContext(dialCtx, network, address)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return MysqlError{CONNECTING_FAILED, this, err}
+	}
+	this.stream = NewMysqlStream(conn)
+	this.stream.readTimeout = this.config.ReadTimeout
+	this.stream.writeTimeout = this.config.WriteTimeout
+	this.stream.log = this.log
+	this.stream.config = &this.config
+	this.state = CONNECTED
+	this.stream.serverConfig = this.serverConfig
+	return nil
+}
+
+type RowHistory struct {
+	NewValues []ColumnValueType
+	Values    []ColumnValueType
+}
+
+func (this RowHistory) String() string {
+	buf := bytes.NewBufferString("{Type:RowHistory, NewValues:[")
+	for _, v := range this.NewValues {
+		buf.WriteString(v.String())
+		buf.WriteString(",")
+	}
+	buf.WriteString("], Values:[")
+	for _, v := range this.Values {
+		buf.WriteString(v.String())
+		buf.WriteString(",")
+	}
+	buf.WriteString("]}")
+	return buf.String()
+}
+
+// event的元数据，取自event header
+type EventMetaType struct {
+	Timestamp time.Time   // 发生的时间，精确到秒
+	ServerId  uint32      // 最初执行这个语句的服务器的server_id
+	EventSize uint32      // event的长度
+	LogPos    uint32      // 下一个event的位置
+	Binlog    BinglogType // 这个event所在的binlog文件和开始位置
+}
+
+func NewEventMeta(eventHeader EventHeaderType, filename string) EventMetaType {
+	ret := EventMetaType{}
+	ret.Timestamp = time.Unix(int64(eventHeader.Timestamp), 0)
+	ret.ServerId = uint32(eventHeader.ServerId)
+	ret.EventSize = uint32(eventHeader.EventSize)
+	ret.LogPos = uint32(eventHeader.LogPos)
+	ret.Binlog.Filename = filename
+	if ret.LogPos >= ret.EventSize {
+		ret.Binlog.BinlogPos = ret.LogPos - ret.EventSize
+	}
+	return ret
+}
+func (this EventMetaType) String() string {
+	return fmt.Sprintf("{Type:EventMeta, Timestamp:%v, ServerId:%v, EventSize:%v, LogPos:%v, Binlog:%v:%v}", this.Timestamp, this.ServerId, this.EventSize, this.LogPos, this.Binlog.Filename, this.Binlog.BinlogPos)
+}
+
+type DataHistory struct {
+	EventMeta EventMetaType
+	Schema    string
+	Table     string
+	Rows      []RowHistory
+}
+
+func (this DataHistory) String() string {
+	buf := bytes.NewBufferString("{Type:DataHistory, EventMeta:" + this.EventMeta.String() + ", Rows:[")
+	for _, v := range this.Rows {
+		buf.WriteString(v.String())
+		buf.WriteString(",")
+	}
+	buf.WriteString("]}")
+	return buf.String()
+}
+func NewDataHistory(columnValue RowsEventType, tableMap *TableMapEventType) DataHistory {
+	ret := DataHistory{}
+	if tableMap != nil {
+		ret.Schema = string(tableMap.SchemaName)
+		ret.Table = string(tableMap.TableName)
+	}
+	for _, row := range columnValue.Rows {
+		rowHistory := RowHistory{}
+		rowHistory.NewValues = row.Value2
+		rowHistory.Values = row.Value1
+		ret.Rows = append(ret.Rows, rowHistory)
+	}
+	return ret
+}
+
+type CallbackInterface interface {
+	// 执行SQL，只有语句本身。需要当前库、位置、执行时间、错误码时，
+	// callback再实现QueryHistoryCallbackInterface，这时调用OnQueryHistory，不再调用OnQuery
+	OnQuery(sql string)
+	// 插入、更新、删除
+	OnInsert(row DataHistory)
+	OnUpdate(row DataHistory)
+	OnDelete(row DataHistory)
+	// 当无事件时调用。如果返回true表示结束。如果返回false会继续等新的事件
+	OnEnd() bool
+	// 当缺少表结构时调用。在TABLE_MAP_EVENT时调用，colIdx是第一个没有属性的列，表完全未知时为0
+	// Config.LoadSchema打开时，只有查询之后仍然缺少才调用
+	OnColumnAttr(schema, table string, colIdx int)
+	// OnError()
+}
+
+// 执行的SQL
+type QueryHistory struct {
+	EventMeta     EventMetaType
+	Schema        string // 执行时的当前库
+	Query         string
+	ExecutionTime uint32 // 执行用的秒数
+	ErrorCode     uint16 // 在master上执行时的错误码
+}
+
+func (this QueryHistory) String() string {
+	return fmt.Sprintf("{Type:QueryHistory, EventMeta:%v, Schema:%v, Query:%v, ExecutionTime:%v, ErrorCode:%v}", this.EventMeta, this.Schema, this.Query, this.ExecutionTime, this.ErrorCode)
+}
+
+// 可选的回调。callback同时实现了这个接口时，调用OnQueryHistory代替OnQuery
+type QueryHistoryCallbackInterface interface {
+	OnQueryHistory(query QueryHistory)
+}
+
+// 事务中的一次行变化
+type RowChange struct {
+	Command RowsEvenCommand
+	Row     DataHistory
+}
+
+// 一个事务。BEGIN时只有GTID，COMMIT时才有全部的内容
+type TransactionType struct {
+	GTID      string      // uuid:gno，服务器没有打开gtid_mode时为空
+	Xid       uint64      // XID_EVENT中的xid。非事务引擎以COMMIT语句结束，或者事务外的DDL时为0
+	Timestamp time.Time   // 提交时间。8.0.1之后取GTID_EVENT中的微秒时间，之前取提交event的秒
+	Changes   []RowChange // 事务中的行变化，按发生的顺序
+	Queries   []string    // 事务中除BEGIN/COMMIT/ROLLBACK以外的语句，事务外的DDL也作为只有一个语句的事务
+}
+
+func (this TransactionType) String() string {
+	return fmt.Sprintf("{Type:Transaction, GTID:%v, Xid:%v, Timestamp:%v, Changes:%v, Queries:%v}", this.GTID, this.Xid, this.Timestamp, len(this.Changes), this.Queries)
+}
+
+// 可选的回调。传给Replicate的callback同时实现了这个接口时，按事务回调
+// CallbackInterface中的OnInsert等仍然会逐个调用
+type TransactionCallbackInterface interface {
+	OnBegin(trx TransactionType)
+	// 事务提交，trx.Changes是整个事务的行变化，可以原子地应用到下游
+	OnCommit(trx TransactionType)
+	// 以ROLLBACK结束。只有修改了非事务表的事务才会以ROLLBACK写入binlog
+	OnRollback(trx TransactionType)
+}
+
+// 复制时连接断开，Config.Reconnect打开时回调。callback实现了这个接口时才调用
+type ReconnectCallbackInterface interface {
+	// attempt从1开始，是连续第几次重连；err是断开的原因。调用之后等待一段时间再重连
+	OnReconnect(attempt int, err error)
+}
+
+func (this *MysqlServer) Open() error {
+	return this.OpenContext(context.Background())
+}
+
+// 与Open相同。ctx被取消时关闭连接，返回ctx.Err()
+func (this *MysqlServer) OpenContext(ctx context.Context) error {
+	if this.state != CONNECTED {
+		return MysqlError{NOT_CONNECTED, this, nil}
+	}
+	stop := this.watchContext(ctx)
+	err := this.handshake(this.config.User, this.config.Pass, "")
+	if stop() {
+		this.close()
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	this.state = HANDSHAKED
+	return nil
+}
+
+// 断开与服务器的连接：发送COM_QUIT，关闭socket，结束读写网络的goroutine。之后可以重新Connect
+// Replicate进行中时(可以在回调中或另一个goroutine里)只关闭socket，由Replicate所在的goroutine清理连接，
+// 这时不发送COM_QUIT。Replicate返回nil，即使打开了Config.Reconnect也不重连
+func (this *MysqlServer) Close() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.closed.Store(true)
+	if this.cancel != nil {
+		this.cancel()
+		return nil
+	}
+	return this.close()
+}
+
+// 重连前关闭旧的连接，与Close不同，不停止Replicate
+// 只在使用连接的goroutine里调用，这时读写网络的goroutine是空闲的
+func (this *MysqlServer) close() error {
+	if this.stream == nil {
+		return nil
+	}
+	if this.state == HANDSHAKED {
+		// 通知服务器断开。不经过读写的goroutine，服务器不读时最多等待1秒。失败也继续关闭
+		this.stream.initSequenceId()
+		this.stream.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		this.stream.conn.Write(this.stream.encodePacket(NewComQuit()))
+	}
+	err := this.stream.close()
+	this.stream = nil
+	this.state = UNCONNECTED
+	// 自己建立的查询表结构的连接也关闭，下次查询时重新连接
+	if loader, ok := this.schemaLoader.(*InformationSchemaLoader); ok && this.config.SchemaLoader == nil {
+		loader.Close()
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+// ctx被取消时关闭连接，使阻塞在网络上的读写返回错误
+// 返回的stop用于结束监视，返回值表示连接是否因为ctx被取消而关闭了
+func (this *MysqlServer) watchContext(ctx context.Context) (stop func() bool) {
+	if ctx.Done() == nil {
+		// 不会被取消，如context.Background()
+		return func() bool { return false }
+	}
+	// 握手时连接可能被替换为TLS等，这里关闭最底层的连接
+	conn := this.stream.conn
+	done := make(chan struct{})
+	canceled := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			canceled <- true
+		case <-done:
+			canceled <- false
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-canceled
+	}
+}
+
+func (this *MysqlServer) Replicate(callback CallbackInterface) error {
+	return this.ReplicateContext(context.Background(), callback)
+}
+
+// 与Replicate相同。ctx被取消时关闭连接，返回ctx.Err()，用于在程序退出时停止复制
+// 断点只在事务边界保存，所以停止后可以用Continue从上次保存的位置继续
+// Config.Reconnect打开时，网络错误不返回，而是重连后从最后提交的位置继续。
+// 断开时未提交事务中的行变化已经回调过，重连后会从事务开头再回调一次
+func (this *MysqlServer) ReplicateContext(ctx context.Context, callback CallbackInterface) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// Close通过cancel使阻塞在网络上的读写和重连前的等待返回
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	this.mutex.Lock()
+	if this.state != HANDSHAKED {
+		this.mutex.Unlock()
+		return MysqlError{NOT_HANDSHAKED, this, nil}
+	}
+	this.closed.Store(false)
+	this.cancel = cancel
+	this.mutex.Unlock()
+	defer func() {
+		this.mutex.Lock()
+		this.cancel = nil
+		this.mutex.Unlock()
+	}()
+	var resume *Checkpoint
+	attempt := 0
+	for {
+		stop := this.watchContext(ctx)
+		err := this.replicate(callback, resume)
+		canceled := stop()
+		if this.closed.Load() {
+			// 连接是被Close关闭的，不是网络错误
+			this.close()
+			return nil
+		}
+		if canceled {
+			this.close()
+			return ctx.Err()
+		}
+		if err == nil || !this.config.Reconnect || !isNetworkError(err) {
+			return err
+		}
+		if this.progressed(resume) {
+			// 上次重连之后有事务提交，重新计算等待时间
+			attempt = 0
+		}
+		resume = this.committed
+		this.close()
+		attempt, err = this.reconnect(ctx, callback, attempt, err)
+		if this.closed.Load() {
+			// 重连时被Close
+			this.close()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// 复制断开后重连，直到成功、ctx被取消或者超过Config.MaxReconnects
+// attempt是之前已经连续重连的次数，返回成功时的次数
+func (this *MysqlServer) reconnect(ctx context.Context, callback CallbackInterface, attempt int, err error) (int, error) {
+	reconnectCallback, _ := callback.(ReconnectCallbackInterface)
+	for {
+		attempt++
+		if this.config.MaxReconnects > 0 && attempt > this.config.MaxReconnects {
+			return attempt, err
+		}
+		wait := this.reconnectWait(attempt)
+		this.Log(LogWarning, fmt.Sprintf("Server=%s:%v replication stopped by %v, reconnect #%d in %v", this.config.Host, this.config.Port, err, attempt, wait))
+		if reconnectCallback != nil {
+			reconnectCallback.OnReconnect(attempt, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// ctx被取消，或者被Close
+			timer.Stop()
+			return attempt, ctx.Err()
+		}
+		if err = this.ConnectContext(ctx); err == nil {
+			if err = this.OpenContext(ctx); err == nil {
+				this.Log(LogWarning, fmt.Sprintf("Server=%s:%v reconnected", this.config.Host, this.config.Port))
+				return attempt, nil
+			}
+			this.close()
+		}
+		if ctx.Err() != nil {
+			return attempt, ctx.Err()
+		}
+		if !isNetworkError(err) {
+			// 如密码错误等，重连也不会成功
+			return attempt, err
+		}
+	}
+}
+
+// 第attempt次重连前等待的时间：指数增长，取[d/2, d)之间的随机值，避免多个客户端同时重连
+func (this *MysqlServer) reconnectWait(attempt int) time.Duration {
+	wait, max := this.config.ReconnectBackoff, this.config.MaxReconnectWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+	if max <= 0 {
+		max = time.Minute
+	}
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// 从resume开始复制之后，是否有事务提交
+func (this *MysqlServer) progressed(resume *Checkpoint) bool {
+	if resume == nil || this.committed == nil {
+		return true
+	}
+	return this.committed.Binlog != resume.Binlog || this.committed.GTIDSet != resume.GTIDSet
+}
+
+// 网络断开、超时等重连后可能恢复的错误。服务器返回的错误(如binlog已被purge)不重连
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	if _, ok := err.(EOFError); ok || err == errStreamClosed {
+		return true
+	}
+	if mysqlError, ok := err.(MysqlError); ok {
+		return mysqlError.Code == CONNECTING_FAILED
+	}
+	return false
+}
+
+// resume不为nil时是重连，从这个位置继续，忽略Config中开始的位置
+func (this *MysqlServer) replicate(callback CallbackInterface, resume *Checkpoint) error {
+	// FORMAT_DESCRIPTION_EVENT之前没有CRC，之后由它的校验算法决定
+	this.serverConfig.CrcSize = 0
+	if this.serverConfig.compareVersion("5.6.2") >= 0 {
+		// 告诉服务器自己能处理checksum，使用服务器的校验算法。之后读event时校验CRC32
+		// 设置为NONE时，服务器伪造的rotate没有CRC，binlog中的event仍然带着服务器的CRC
+		sql := "SET @master_binlog_checksum=@@global.binlog_checksum"
+		if this.config.DisableChecksum {
+			sql = "SET @master_binlog_checksum='NONE'"
+		}
+		com := NewComQuery(sql)
+		writeResultRet := this.stream.WriteCom(com)
+		if writeResultRet.err != nil {
+			return writeResultRet.err
+		}
+		comResponse, err := this.stream.Read()
+		if err != nil {
+			return err
+		}
+		if _, ok := comResponse.(OKPacket); ok {
+		} else if errPacket, ok := comResponse.(ErrPacket); ok {
+			return this.errorByErrPacket(errPacket)
+		} else {
+			return this.errorNotExpectedPacket(comResponse)
+		}
+		if !this.config.DisableChecksum {
+			// 服务器伪造的rotate在FORMAT_DESCRIPTION_EVENT之前，是否带CRC取决于上面的设置
+			resultSet, err := this.query("SELECT @master_binlog_checksum")
+			if err != nil {
+				return err
+			}
+			if alg, ok := resultSet.GetString(0, "@master_binlog_checksum"); ok && strings.EqualFold(alg, "CRC32") {
+				this.serverConfig.CrcSize = BinlogChecksumLength
+			}
+		}
+	}
+
+	// 把自己注册成一个Slave
+	registerCom := NewComRegisterSlave()
+	writeResultRet := this.stream.WriteCom(registerCom)
+	if writeResultRet.err != nil {
+		return writeResultRet.err
+	}
+	reisterComResponse, err := this.stream.Read()
+	if err != nil {
+		return err
+	}
+	if errPacket, ok := reisterComResponse.(ErrPacket); ok {
+		return this.errorByErrPacket(errPacket)
+	}
+	if _, ok := reisterComResponse.(OKPacket); !ok {
+		return this.errorNotExpectedPacket(reisterComResponse)
+	}
+
+	// DUMP表
+	// Mysql 5.5.62不支持，会报ErrPacket {Type:ErrPacket, Header:255, ErrorCode:1047, SqlStateMarker:, SqlState:, ErrorMessage:#08S01Unknown command,
+	//dumpTableCom := NewComTableDump()
+	//writeResultRet = this.stream.WriteCom(dumpTableCom)
+	//if writeResultRet.err != nil{
+	//return writeResultRet.err
+	//}
+
+	var filename string
+	var binlogPos uint32
+	var gtidSet GTIDSet // 不为nil时用COM_BINLOG_DUMP_GTID
+	if resume != nil {
+		filename = resume.Binlog.Filename
+		binlogPos = resume.Binlog.BinlogPos
+		if this.config.DumpFrom == DumpFromGTID {
+			if gtidSet, err = ParseGTIDSet(resume.GTIDSet); err != nil {
+				return this.LogError(err)
+			}
+		}
+	} else if this.config.Continue {
+		if this.storage != nil {
+			// 读上次保存的断点
+			var checkpoint *Checkpoint
+			if checkpoint, err = this.storage.Load(); err != nil {
+				return this.LogError(err)
+			}
+			if checkpoint != nil {
+				filename = checkpoint.Binlog.Filename
+				binlogPos = checkpoint.Binlog.BinlogPos
+				if checkpoint.Columns != nil {
+					this.serverConfig.Columns = checkpoint.Columns
+				}
+				if this.config.DumpFrom == DumpFromGTID && checkpoint.GTIDSet != "" {
+					if gtidSet, err = ParseGTIDSet(checkpoint.GTIDSet); err != nil {
+						return this.LogError(err)
+					}
+				}
+			}
+		}
+	}
+	var jump uint32
+	// 如果读不到，或者continue为false
+	if resume == nil && (filename == "" || !this.config.Continue) {
+		switch this.config.DumpFrom {
+		case DumpFromBeginning:
+			// show binary logs，从还存在的最早的binlog开始。文件名取决于log_bin_basename，不能写死
+			var binlogs []string
+			if binlogs, err = this.binaryLogs(); err != nil {
+				return err
+			}
+			filename = binlogs[0]
+			binlogPos = 4
+		case DumpFromLatest:
+			// show master status，取得当前位置
+			var binlog BinglogType
+			if binlog, err = this.masterStatus(); err != nil {
+				return err
+			}
+			filename = binlog.Filename
+			binlogPos = binlog.BinlogPos
+		case DumpFromPosition:
+			// 后两种情况特殊，不能从指定的位置开始。因为binglog第一条是format_description_event
+			// 如果不读这个记录，后面会报错。所以只能从第1条开始读，然后前面的跳过
+			filename = this.config.BinlogPosition.Filename
+			binlogPos = this.config.BinlogPosition.BinlogPos
+		case DumpFromGTID:
+			// 服务器根据gtid集合找到开始的位置，会先发rotate告诉文件名
+			if gtidSet, err = ParseGTIDSet(this.config.GTIDSet); err != nil {
+				return this.LogError(err)
+			}
+		}
+		// 这里后两种需要判断一下，如果当前记下来的format_description_event不是指定文件的，需要从头开始读，再跳过
+		if gtidSet == nil && filename != this.serverConfig.BinlogFilename {
+			jump = binlogPos
+			binlogPos = 4
+		}
+	}
+
+	this.serverConfig.BinlogFilename = filename
+	this.serverConfig.BinlogPosition = binlogPos
+	// 开始的位置也是事务边界，还没有事务提交就断开时从这里重连
+	this.committed = &Checkpoint{Binlog: BinglogType{filename, binlogPos}}
+	if jump != 0 {
+		this.committed.Binlog.BinlogPos = jump
+	}
+	if gtidSet != nil {
+		this.committed.GTIDSet = gtidSet.String()
+	}
+
+	if gtidSet != nil {
+		this.serverConfig.GTIDSet = gtidSet
+		// 文件名为空、位置为4时，服务器只根据gtid集合决定从哪里开始
+		dumpBinlogGTIDCom := NewComBinlogDumpGTID(this.config.ServerId, "", 4, gtidSet)
+
+		// 非阻塞
+		dumpBinlogGTIDCom.Flags |= BINLOG_DUMP_NON_BLOCK
+
+		writeResultRet = this.stream.WriteCom(dumpBinlogGTIDCom)
+	} else {
+		dumpBinlogCom := NewComBinlogDump(this.config.ServerId, filename, binlogPos)
+
+		// 非阻塞
+		dumpBinlogCom.Flags = BINLOG_DUMP_NON_BLOCK
+
+		writeResultRet = this.stream.WriteCom(dumpBinlogCom)
+	}
+	if writeResultRet.err != nil {
+		return writeResultRet.err
+	}
+
+	// BEGIN之后到COMMIT/XID之前为true。只在事务之外保存断点
+	inTransaction := false
+	// 当前事务的GTID，提交时加入serverConfig.GTIDSet
+	var gtidEvent *GtidEventType
+	// 当前的事务，callback实现了TransactionCallbackInterface时才使用
+	trxCallback, _ := callback.(TransactionCallbackInterface)
+	queryCallback, _ := callback.(QueryHistoryCallbackInterface)
+	var trx *TransactionType
+	for {
+		pkt, err := this.stream.ReadEvent()
+		if err == nil {
+			// 从头读到jump之前的event只用来解析后面的event，不回调给用户
+			skip := false
+			var eventHeader EventHeaderType
+			if fullEvent, ok := pkt.(FullEvent); ok {
+				pkt = fullEvent.event
+				eventHeader = fullEvent.eventHeader
+				// LogPos是下一个event的位置。服务器伪造的event(如开头的rotate、format_description)为0，不能用
+				if fullEvent.eventHeader.LogPos != 0 {
+					this.serverConfig.BinlogPosition = uint32(fullEvent.eventHeader.LogPos)
+					if jump != 0 {
+						if uint32(fullEvent.eventHeader.LogPos) <= jump {
+							skip = true
+						} else {
+							jump = 0
+						}
+					}
+				}
+			}
+			if _, ok := pkt.(EOFPacket); ok {
+				if callback.OnEnd() {
+					return nil
+				}
+			} else if errPacket, ok := pkt.(ErrPacket); ok {
+				return this.errorByDumpErrPacket(errPacket, filename, binlogPos)
+			} else if formatDescriptionEvent, ok := pkt.(FormatDescriptionEventType); ok {
+				this.serverConfig.EventTypeHeaderLength = formatDescriptionEvent.EventTypeHeaderLength
+				// 之后的event是否带CRC
+				if formatDescriptionEvent.ChecksumAlg == BINLOG_CHECKSUM_ALG_CRC32 {
+					this.serverConfig.CrcSize = BinlogChecksumLength
+				} else {
+					this.serverConfig.CrcSize = 0
+				}
+			} else if rotateEvent, ok := pkt.(RotateEventType); ok {
+				// 切换到下一个binlog文件。dump开始时服务器也会先发一个rotate，告诉当前的文件名
+				this.serverConfig.BinlogFilename = string(rotateEvent.Name)
+				this.serverConfig.BinlogPosition = uint32(rotateEvent.Position)
+				// 还没跳到指定位置时，rotate的位置是回退后的位置，不能保存
+				if !inTransaction && jump == 0 {
+					if err = this.saveCheckpoint(); err != nil {
+						return err
+					}
+				}
+			} else if tableMapEvent, ok := pkt.(TableMapEventType); ok {
+				this.serverConfig.TableMaps[Uint8(tableMapEvent.TableId)] = tableMapEvent
+				this.serverConfig.applyTableMap(tableMapEvent)
+				this.loadColumns(callback, tableMapEvent)
+			} else if queryEvent, ok := pkt.(QueryEventType); ok && skip {
+				// 指定位置之前的query已经处理过了，但DDL还要用来更新表结构，后面的行才能对应上列名
+				this.applyDDL(string(queryEvent.Schema), string(queryEvent.Query))
+			} else if skip {
+				// 指定位置之前的行变化已经处理过了，跳过
+			} else if event, ok := pkt.(GtidEventType); ok {
+				gtidEvent = &event
+			} else if xidEvent, ok := pkt.(XIDEventType); ok {
+				// InnoDB等事务引擎的COMMIT
+				inTransaction = false
+				if trx != nil {
+					trx.Xid = uint64(xidEvent.Xid)
+					trx.Timestamp = commitTime(gtidEvent, eventHeader)
+					trxCallback.OnCommit(*trx)
+					trx = nil
+				}
+				this.commitGTID(gtidEvent)
+				gtidEvent = nil
+				if err = this.saveCheckpoint(); err != nil {
+					return err
+				}
+			} else if rowsEvent, ok := pkt.(RowsEventType); ok {
+				var tableMap *TableMapEventType
+				if tm, ok := this.serverConfig.TableMaps[Uint8(rowsEvent.TableId)]; ok {
+					tableMap = &tm
+				}
+				row := NewDataHistory(rowsEvent, tableMap)
+				row.EventMeta = NewEventMeta(eventHeader, this.serverConfig.BinlogFilename)
+				switch rowsEvent.Command {
+				case RowsEvenCommandInsert:
+					callback.OnInsert(row)
+				case RowsEvenCommandUpdate:
+					callback.OnUpdate(row)
+				case RowsEvenCommandDelete:
+					callback.OnDelete(row)
+				}
+				if trx != nil {
+					trx.Changes = append(trx.Changes, RowChange{rowsEvent.Command, row})
+				}
+			} else if queryEvent, ok := pkt.(QueryEventType); ok {
+				//fmt.Println("queryEvent=", queryEvent)
+				query := strings.ToUpper(strings.TrimSpace(string(queryEvent.Query)))
+				if query == "BEGIN" {
+					inTransaction = true
+					if trxCallback != nil {
+						trx = newTransaction(gtidEvent)
+						trxCallback.OnBegin(*trx)
+					}
+				} else if query != "COMMIT" && query != "ROLLBACK" && trxCallback != nil {
+					if trx == nil {
+						// 事务之外的语句(DDL等)，单独作为一个事务
+						trx = newTransaction(gtidEvent)
+						trxCallback.OnBegin(*trx)
+					}
+					trx.Queries = append(trx.Queries, string(queryEvent.Query))
+				}
+				this.applyDDL(string(queryEvent.Schema), string(queryEvent.Query))
+				if queryCallback != nil {
+					queryHistory := QueryHistory{}
+					queryHistory.EventMeta = NewEventMeta(eventHeader, this.serverConfig.BinlogFilename)
+					queryHistory.Schema = string(queryEvent.Schema)
+					queryHistory.Query = string(queryEvent.Query)
+					queryHistory.ExecutionTime = uint32(queryEvent.ExecutionTime)
+					queryHistory.ErrorCode = uint16(queryEvent.ErrorCode)
+					queryCallback.OnQueryHistory(queryHistory)
+				} else {
+					callback.OnQuery(string(queryEvent.Query))
+				}
+				// 非事务引擎以COMMIT/ROLLBACK语句结束；DDL会隐式提交，不在事务中
+				if query == "COMMIT" || query == "ROLLBACK" {
+					inTransaction = false
+				}
+				if !inTransaction {
+					if trx != nil {
+						trx.Timestamp = commitTime(gtidEvent, eventHeader)
+						if query == "ROLLBACK" {
+							trxCallback.OnRollback(*trx)
+						} else {
+							trxCallback.OnCommit(*trx)
+						}
+						trx = nil
+					}
+					this.commitGTID(gtidEvent)
+					gtidEvent = nil
+					if err = this.saveCheckpoint(); err != nil {
+						return err
+					}
+				}
+			}
+			//if _, ok := pkt.(RowsEventType); ok{
+			//	break;
+			//}
+			// 在这里处理给用户的回调
+		} else {
+			return err
+		}
+	}
+}
+
+// 解析DDL，更新缓存的表结构。schema是QUERY_EVENT中的当前库
+func (this *MysqlServer) applyDDL(schema, query string) {
+	tableAsts, err := parseSql(query)
+	if err != nil {
+		// sql 解析失败，有可能是一些无法识别的类型导致的。比如 ruiaylin/sqlparser 不支持geometry类型，也可能是合法的语句如BEGIN等。这种情况只能先跳过了
+		//fmt.Println("parseSql err=", err)
+	} else {
+		//fmt.Println("tableAsts=", tableAsts)
+		for _, tableAst := range tableAsts {
+			// queryEvent.Schema 有时是“”，空字符串。怀疑是mysql的bug(5.5.62)。当这里是空字符串时，用下面sql解析出来的schema
+			// Q_UPDATED_DB_NAMES 表示当前DB？
+			if schema == "" {
+				schema = tableAst.Schema
+			}
+			if schema != tableAst.Schema && schema == "" && tableAst.Schema != "" {
+				// binlog中的schema与解析出来的不相符？
+				panic("binlog schema != tableAstSchema, binlog schema=" + schema + " tableAst.schema=" + tableAst.Schema)
+			}
+
+			if _, ok := this.serverConfig.Columns[schema]; !ok {
+				this.serverConfig.Columns[schema] = make(TableAttrs)
+			}
+			delete(this.schemaNotFound, schema+"."+tableAst.Name)
+			delete(this.columnAttrReported, schema+"."+tableAst.Name)
+			if tableAst.Action == ActionAlter && this.schemaLoader != nil {
+				// 只能解析出ADD/DROP COLUMN，不如下次用到时重新查询准确
+				delete(this.serverConfig.Columns[schema], tableAst.Name)
+				continue
+			}
+			if _, ok := this.serverConfig.Columns[schema][string(tableAst.Name)]; !ok {
+				this.serverConfig.Columns[schema][string(tableAst.Name)] = make(TableAttr, 0)
+			}
+
+			//fmt.Println("Action=", tableAst.Action, " Schema=", tableAst.Schema, " Table=", tableAst.Name)
+			for _, col := range tableAst.Cols {
+				// fmt.Println("col.Name=", col.Name)
+				// fmt.Println("col.ColumnType=", col.ColumnType)
+				// fmt.Println("col.Unsigned=", col.Unsigned)
+				// fmt.Println("col.SetParams=", col.SetParams)
+				// fmt.Println("col.Position=", col.Position)
+				// fmt.Println("col.AddAfter=", col.AddAfter)
+				// fmt.Println("col.Drop=", col.Drop)
+
+				switch tableAst.Action {
+				case ActionCreate:
+					columnAttr := NewColumnAttr(col)
+					this.serverConfig.Columns[schema][tableAst.Name] = append(this.serverConfig.Columns[schema][tableAst.Name], columnAttr)
+
+				case ActionAlter:
+					//fmt.Println("ActionAlter=", queryEvent.Query)
+					if col.Drop {
+						// 删除一列
+						colIdx := -1
+						for idx, colFound := range this.serverConfig.Columns[schema][tableAst.Name] {
+							if colFound.Name == col.Name {
+								colIdx = idx
+								break
+							}
+						}
+						if colIdx != -1 {
+							l := len(this.serverConfig.Columns[schema][tableAst.Name])
+							if colIdx == 0 {
+								this.serverConfig.Columns[schema][tableAst.Name] = this.serverConfig.Columns[schema][tableAst.Name][1:]
+							} else if colIdx == l-1 {
+								this.serverConfig.Columns[schema][tableAst.Name] = this.serverConfig.Columns[schema][tableAst.Name][:l-1]
+							} else {
+								tmp := this.serverConfig.Columns[schema][tableAst.Name][0:colIdx]
+								tmp = append(tmp, this.serverConfig.Columns[schema][tableAst.Name][colIdx+1:]...)
+								this.serverConfig.Columns[schema][tableAst.Name] = tmp
+							}
+						}
+					} else {
+						// 新增一列
+						columnAttr := NewColumnAttr(col)
+						if col.Position == AddColumnAtTail {
+							this.serverConfig.Columns[schema][tableAst.Name] = append(this.serverConfig.Columns[schema][tableAst.Name], columnAttr)
+						} else if col.Position == AddColumnAtFirst {
+							tmp := make([]ColumnAttr, 0)
+							tmp = append(tmp, columnAttr)
+							this.serverConfig.Columns[schema][tableAst.Name] = append(tmp, this.serverConfig.Columns[schema][tableAst.Name]...)
+						} else {
+						}
+					}
+				}
+
+			}
+		}
+	}
+}
+
+// 查询表结构失败后，重新查询前等待的时间
+const schemaLoadRetryInterval = 10 * time.Second
+
+// 表结构未知，或者列数与TABLE_MAP_EVENT不同(如DDL没有解析出来)时，用schemaLoader查询并缓存
+// 查询之后仍然缺少时回调OnColumnAttr，这时行数据中的列名为空。每个表只回调一次，直到下一个DDL
+func (this *MysqlServer) loadColumns(callback CallbackInterface, tableMap TableMapEventType) {
+	schema, table := string(tableMap.SchemaName), string(tableMap.TableName)
+	tableAttr, ok := this.serverConfig.Columns[schema][table]
+	if ok && len(tableAttr) == len(tableMap.ColumnDef) {
+		return
+	}
+	key := schema + "." + table
+	if this.schemaLoader != nil && !this.schemaNotFound[key] && !time.Now().Before(this.schemaLoadRetry) {
+		loaded, err := this.schemaLoader.LoadColumns(schema, table)
+		if err != nil {
+			// 查询失败不影响复制。可能是连不上服务器，一段时间内不再查询，避免每个event都重连
+			this.schemaLoadRetry = time.Now().Add(schemaLoadRetryInterval)
+			this.Log(LogWarning, fmt.Sprintf("Server=%s:%v load columns of %s failed, retry after %v, %v", this.config.Host, this.config.Port, key, schemaLoadRetryInterval, err))
+		} else if len(loaded) == len(tableMap.ColumnDef) {
+			if _, ok := this.serverConfig.Columns[schema]; !ok {
+				this.serverConfig.Columns[schema] = make(TableAttrs)
+			}
+			this.serverConfig.Columns[schema][table] = loaded
+			// binlog_row_metadata=MINIMAL时的符号比查询到的准确
+			this.serverConfig.applyTableMap(tableMap)
+			return
+		} else {
+			// 表已经被删除或修改过，查询到的是现在的结构，与binlog中的不同
+			this.schemaNotFound[key] = true
+		}
+	}
+	if len(tableAttr) < len(tableMap.ColumnDef) && !this.columnAttrReported[key] {
+		this.columnAttrReported[key] = true
+		callback.OnColumnAttr(schema, table, len(tableAttr))
+	}
+}
+
+// 当前复制到的binlog位置，即下一个要读的event
+func (this *MysqlServer) Position() BinglogType {
+	return BinglogType{this.serverConfig.BinlogFilename, this.serverConfig.BinlogPosition}
+}
+
+func newTransaction(gtidEvent *GtidEventType) *TransactionType {
+	ret := &TransactionType{}
+	if gtidEvent != nil {
+		ret.GTID = gtidEvent.GTID()
+	}
+	return ret
+}
+
+// 事务的提交时间。GTID_EVENT中有微秒精度的时间时优先用它
+func commitTime(gtidEvent *GtidEventType, eventHeader EventHeaderType) time.Time {
+	if gtidEvent != nil && gtidEvent.ImmediateCommitTimestamp != 0 {
+		return gtidEvent.ImmediateCommitTime()
+	}
+	return time.Unix(int64(eventHeader.Timestamp), 0)
+}
+
+// 事务提交后，把它的GTID加入已执行的集合
+func (this *MysqlServer) commitGTID(gtidEvent *GtidEventType) {
+	if gtidEvent != nil {
+		this.serverConfig.GTIDSet.Add(formatUUID([]byte(gtidEvent.SID)), int64(gtidEvent.GNO))
+	}
+}
+
+// 在事务边界记下当前位置用于重连，并把位置和表结构保存到storage
+func (this *MysqlServer) saveCheckpoint() error {
+	checkpoint := Checkpoint{}
+	checkpoint.Binlog = this.Position()
+	checkpoint.GTIDSet = this.serverConfig.GTIDSet.String()
+	this.committed = &Checkpoint{Binlog: checkpoint.Binlog, GTIDSet: checkpoint.GTIDSet}
+	if this.storage == nil {
+		return nil
+	}
+	checkpoint.Columns = this.serverConfig.Columns
+	if err := this.storage.Save(checkpoint); err != nil {
+		return this.LogError(err)
+	}
+	return nil
+}
+
+// 执行一条返回结果集的SQL（文本协议）
+func (this *MysqlServer) query(sql string) (ResultSetType, error) {
+	var ret ResultSetType
+	if this.state != HANDSHAKED {
+		return ret, MysqlError{NOT_HANDSHAKED, this, nil}
+	}
+	writeResultRet := this.stream.WriteCom(NewComQuery(sql))
+	if writeResultRet.err != nil {
+		return ret, writeResultRet.err
+	}
+	response, err := this.stream.ReadResultSet()
+	if err != nil {
+		return ret, err
+	}
+	if resultSet, ok := response.(ResultSetType); ok {
+		return resultSet, nil
+	} else if errPacket, ok := response.(ErrPacket); ok {
+		return ret, this.errorByErrPacket(errPacket)
+	}
+	return ret, this.errorNotExpectedPacket(response)
+}
+
+// 取得master当前写到的binlog文件和位置
+func (this *MysqlServer) masterStatus() (BinglogType, error) {
+	var ret BinglogType
+	// 从8.2开始改名为SHOW BINARY LOG STATUS，8.4中去掉了SHOW MASTER STATUS。MariaDB仍然用原来的名字
+	sql := "SHOW MASTER STATUS"
+	if this.serverConfig.compareVersion("8.2.0") >= 0 && !this.serverConfig.isMariaDB() {
+		sql = "SHOW BINARY LOG STATUS"
+	}
+	resultSet, err := this.query(sql)
+	if err != nil {
+		return ret, err
+	}
+	filename, ok1 := resultSet.GetString(0, "File")
+	position, ok2 := resultSet.GetString(0, "Position")
+	if !ok1 || !ok2 {
+		// 没有打开binlog时返回空的结果集
+		return ret, MysqlError{BINLOG_DISABLED, this, nil}
+	}
+	pos, err := strconv.ParseUint(position, 10, 32)
+	if err != nil {
+		return ret, this.errorNotExpectedPacket(resultSet)
+	}
+	ret.Filename = filename
+	ret.BinlogPos = uint32(pos)
+	return ret, nil
+}
+
+// 列出服务器上现存的binlog文件，按从旧到新的顺序
+func (this *MysqlServer) binaryLogs() ([]string, error) {
+	resultSet, err := this.query("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(resultSet.Rows))
+	for idx := range resultSet.Rows {
+		if filename, ok := resultSet.GetString(idx, "Log_name"); ok {
+			ret = append(ret, filename)
+		}
+	}
+	if len(ret) == 0 {
+		return nil, MysqlError{BINLOG_DISABLED, this, nil}
+	}
+	return ret, nil
+}
+
+// dump过程中收到的ErrPacket。binlog被purge时返回BinlogPurgedError
+func (this *MysqlServer) errorByDumpErrPacket(errPacket ErrPacket, filename string, binlogPos uint32) error {
+	if errPacket.ErrorCode == ER_MASTER_FATAL_ERROR_READING_BINLOG {
+		msg := string(errPacket.ErrorMessage)
+		// 5.x/8.0: Could not find first log file name in binary log index file
+		// GTID: ...but the master has purged binary logs containing GTIDs that the slave requires.
+		if strings.Contains(msg, "first log file") || strings.Contains(msg, "purged") {
+			return BinlogPurgedError{filename, binlogPos, msg}
+		}
+	}
+	return this.errorByErrPacket(errPacket)
+}
+func (this *MysqlServer) errorByErrPacket(errPacket ErrPacket) MysqlError {
+	err := Error{fmt.Sprintf("ErrPacket Code=%d, Msg=%v", errPacket.ErrorCode, errPacket.ErrorMessage), 0}
+	return MysqlError{MYSQL_ERROR, this, err}
+}
+func (this *MysqlServer) errorNotExpectedPacket(packet interface{}) MysqlError {
+	err := Error{fmt.Sprintf("Unexpteced Packet, Type=%v, %v", reflect.TypeOf(packet), packet), 0}
+	return MysqlError{NOT_EXPECTED_PACKET, this, err}
+}
+func (this *MysqlServer) errorMustUse41(packet interface{}) MysqlError {
+	err := Error{fmt.Sprintf("CapabilityFlag_CLIENT_PROTOCOL_41 unsetted, %v", packet), 0}
+	return MysqlError{NOT_EXPECTED_PACKET, this, err}
+}
+func (this *MysqlServer) errorUnknownAuthenticationMethodByAuthSwitchRequest(authMethod string) MysqlError {
+	err := Error{fmt.Sprintf("errorUnknownAuthenticationMethodByAuthSwitchRequest method= %v", authMethod), 0}
+	return MysqlError{NOT_EXPECTED_PACKET, this, err}
+}
+func (this *MysqlServer) errorUnknownAuthenticationMethod(handshakePacket HandshakeV10) MysqlError {
+	err := Error{fmt.Sprintf("Unknown AuthenticationMethod, CLIENT_PROTOCOL_41=%v, CLIENT_SECURE_CONNECTION=%v, CLIENT_PLUGIN_AUTH=%v", CapabilityFlag_CLIENT_PROTOCOL_41.isSet(handshakePacket.CapabilityFlags), CapabilityFlag_CLIENT_SECURE_CONNECTION.isSet(handshakePacket.CapabilityFlags), CapabilityFlag_CLIENT_PLUGIN_AUTH.isSet(handshakePacket.CapabilityFlags)), 0}
+	return MysqlError{NOT_EXPECTED_PACKET, this, err}
+}
+func reserveNumFunc(c rune) bool {
+	var ret bool
+	switch c {
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		ret = false
+	default:
+		ret = true
+	}
+	return ret
+}
+func (this *MysqlServer) handshake(username string, password string, database string) error {
+	// Connected -+--------> HandshakePacket -+----> SSLExchange ------> ClientResponse ------> AuthenticationMethodSwitch ------> Disconnect
+	//            |                           |                           ↑        |                     ↓
+	//            | 连接满等                   +---------------------------+        +----------> AuthenticationExchangeContinuation ------> OK
+	//            +--------> ErrPacket                                             |                     |
+	//                                                                             +---------------------+-------------------------------> Err
+	packet, err := this.stream.Read()
+	// this.printPacket(packet)
+	if err != nil {
+		return err
+	}
+	if errPacket, ok := packet.(ErrPacket); ok {
+		return this.errorByErrPacket(errPacket)
+	}
+	if handshakePacket, ok := packet.(HandshakeV10); !ok {
+		return this.errorNotExpectedPacket(packet)
+	} else {
+		// 只支持Client_4.1
+		if Uint4(handshakePacket.CapabilityFlags)&Uint4(CapabilityFlag_CLIENT_PROTOCOL_41) == 0 {
+			return this.errorMustUse41(packet)
+		}
+		this.stream.serverConfig.Version = string(handshakePacket.ServerVersion)
+		// 从5.6.0后开始支持CRC32校验。event是否带CRC由复制时的设置和FORMAT_DESCRIPTION_EVENT决定
+		if this.stream.serverConfig.compareVersion("5.6.0") >= 0 {
+			this.serverConfig.ServerCrc32CheckFlag = true
+		}
+
+		// 判断Authentication Method
+		if !CapabilityFlag_CLIENT_PLUGIN_AUTH.isSet(handshakePacket.CapabilityFlags) {
+			if CapabilityFlag_CLIENT_PROTOCOL_41.isSet(handshakePacket.CapabilityFlags) && !CapabilityFlag_CLIENT_SECURE_CONNECTION.isSet(handshakePacket.CapabilityFlags) {
+				this.authenticationMethod = OldPasswordAuthentication
+			} else if CapabilityFlag_CLIENT_PROTOCOL_41.isSet(handshakePacket.CapabilityFlags) && CapabilityFlag_CLIENT_SECURE_CONNECTION.isSet(handshakePacket.CapabilityFlags) {
+				this.authenticationMethod = SecurePasswordAuthentication
+			} else {
+				return this.errorUnknownAuthenticationMethod(handshakePacket)
+			}
+		} else {
+			this.authenticationMethod = AuthenticationMethodType(handshakePacket.AuthPluginName)
+		}
+
+		// SSLExchange。之后的HandshakeResponse41和认证都在加密的连接上进行
+		if err = this.startTLS(&handshakePacket); err != nil {
+			return err
+		}
+
+		pluginData := string(handshakePacket.AuthPluginDataPart1 + handshakePacket.AuthPluginDataPart2)
+		if authPluginResponse, err := this.authResponse([]byte(pluginData)); err == nil {
+			password = string(authPluginResponse)
+		} else {
+			return err
+		}
+		handshakeResponse := NewHandshakeResponse41(&handshakePacket, username, password, database, this.authenticationMethod)
+		if this.isSecure() {
+			handshakeResponse.setFlag(Uint4(CapabilityFlag_CLIENT_SSL))
+		}
+		this.negotiateCompression(&handshakePacket, handshakeResponse)
+		// 之后的OK、结果集等按双方都支持的特征解析，如CLIENT_DEPRECATE_EOF
+		this.serverConfig.CapabilityFlags = handshakeResponse.CapabilityFlags
+
+		// 根据文档 dev.mysql.com/doc/internals/en/connection-phase-packets.html ，从5.6.6之后就可以发送变量
+		// 如果不设置这些变量，在replication时会报CRC错误
+		if this.serverConfig.compareVersion("5.6.6") >= 0 {
+			// C->S
+			// bf 00 00 01 05 a2 3e 00 00 00 00 40 08 00 00 00  00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00    ......>....@.... ................
+			// 00 00 00 00 72 65 70 6c 5f 75 73 65 72 00 14 b3  bf 99 85 6f 97 df 88 14 29 10 91 e8 65 74 cc 25    ....repl_user... ...o....)...et.%
+			// cb 80 8a 6d 79 73 71 6c 5f 6e 61 74 69 76 65 5f  70 61 73 73 77 6f 72 64 00 69 03 5f 6f 73 05 4c    ...mysql_native_ password.i._os.L
+			// 69 6e 75 78 0c 5f 63 6c 69 65 6e 74 5f 6e 61 6d  65 08 6c 69 62 6d 79 73 71 6c 04 5f 70 69 64 04    inux._client_nam e.libmysql._pid.
+			// 34 31 33 35 0f 5f 63 6c 69 65 6e 74 5f 76 65 72  73 69 6f 6e 06 35 2e 36 2e 34 36 09 5f 70 6c 61    4135._client_ver sion.5.6.46._pla
+			// 74 66 6f 72 6d 04 69 36 38 36 0c 70 72 6f 67 72  61 6d 5f 6e 61 6d 65 0b 6d 79 73 71 6c 62 69 6e    tform.i686.progr am_name.mysqlbin
+			// 6c 6f 67                                                                                            log
+			handshakeResponse.AddKeyValue("_os", "Linux")
+			handshakeResponse.AddKeyValue("_client_name", "libmysql")
+			handshakeResponse.AddKeyValue("_pid", "4135")
+			handshakeResponse.AddKeyValue("_client_version", strings.TrimFunc(this.stream.serverConfig.Version, reserveNumFunc))
+			handshakeResponse.AddKeyValue("_platform", "i686")
+			handshakeResponse.AddKeyValue("program_name", "mysqlbinlog")
+		}
+		// this.printPacket(handshakeResponse)
+		writeResultRet := this.stream.Write(handshakeResponse)
+		if writeResultRet.err != nil {
+			return writeResultRet.err
+		}
+
+		// 之后服务器可能要求切换认证方法，或者发来AuthMoreData，直到返回OK或Err为止
+		scramble := []byte(pluginData)
+		for {
+			packet, err = this.stream.Read()
+			// this.printPacket(packet)
+			if err != nil {
+				return err
+			}
+			if errPacket, ok := packet.(ErrPacket); ok {
+				return this.errorByErrPacket(errPacket)
+			} else if _, ok := packet.(OKPacket); ok {
+				goto EXIT
+			} else if authMoreData, ok := packet.(AuthMoreData); ok {
+				if err = this.authMoreData(authMoreData, scramble); err != nil {
+					return err
+				}
+				continue
+			}
+
+			// 切换认证方法
+			_, ok1 := packet.(OldAuthSwitchRequest)
+			authSwitchRequest, ok2 := packet.(AuthSwitchRequest)
+			if !ok1 && !ok2 {
+				return this.errorNotExpectedPacket(packet)
+			}
+
+			authSwitchResponse := NewAuthSwitchResponse()
+			if ok1 {
+				this.authenticationMethod = OldPasswordAuthentication
+				scramble = []byte(handshakePacket.AuthPluginDataPart1)
+			} else if ok2 {
+				this.authenticationMethod = AuthenticationMethodType(authSwitchRequest.PluginName)
+				scramble = []byte(authSwitchRequest.AuthPluginData)
+			}
+
+			if authPluginResponse, err := this.authResponse(scramble); err == nil {
+				authSwitchResponse.AuthPluginResponse = StringEof(authPluginResponse)
+			} else {
+				return err
+			}
+			// this.printPacket(authSwitchResponse)
+			writeResultRet = this.stream.Write(authSwitchResponse)
+			if writeResultRet.err != nil {
+				return writeResultRet.err
+			}
+		}
+	}
+EXIT:
+	if this.compression != CompressionNone {
+		// 认证成功后，之后的packet都是压缩的
+		compressed, err := newCompressedConn(this.stream.conn, this.compression)
+		if err != nil {
+			return this.LogError(err)
+		}
+		this.stream.conn = compressed
+	}
+	this.state = HANDSHAKED
+	return nil
+}
+
+// 按Config.Compression和服务器的能力选择压缩算法，设置HandshakeResponse41的标志位
+func (this *MysqlServer) negotiateCompression(handshakePacket *HandshakeV10, handshakeResponse *HandshakeResponse41) {
+	this.compression = CompressionNone
+	if this.config.Compression == CompressionNone {
+		return
+	}
+	if this.config.Compression == CompressionZstd && CapabilityFlag_CLIENT_ZSTD_COMPRESSION_ALGORITHM.isSet(handshakePacket.CapabilityFlags) &&
+		!this.serverConfig.isMariaDB() && this.serverConfig.compareVersion("8.0.18") >= 0 {
+		handshakeResponse.setFlag(Uint4(CapabilityFlag_CLIENT_ZSTD_COMPRESSION_ALGORITHM))
+		handshakeResponse.ZstdCompressionLevel = defaultZstdCompressionLevel
+		this.compression = CompressionZstd
+	} else if CapabilityFlag_CLIENT_COMPRESS.isSet(handshakePacket.CapabilityFlags) {
+		handshakeResponse.setFlag(Uint4(CapabilityFlag_CLIENT_COMPRESS))
+		this.compression = CompressionZlib
+	} else {
+		this.Log(LogWarning, fmt.Sprintf("Server=%s:%v does not support compression", this.config.Host, this.config.Port))
+	}
+}
+
+// 处理认证过程中服务器发来的AuthMoreData
+func (this *MysqlServer) authMoreData(authMoreData AuthMoreData, scramble []byte) error {
+	data := []byte(authMoreData.PluginData)
+	var response []byte
+	switch this.authenticationMethod {
+	case CachingSha2PasswordAuthentication:
+		if len(data) == 1 && data[0] == cachingSha2FastAuthSuccess {
+			// 快速认证成功，后面是OK
+			return nil
+		} else if len(data) == 1 && data[0] == cachingSha2PerformFullAuth {
+			if this.isSecure() {
+				// 连接已经加密，直接发送明文密码
+				response = append([]byte(this.config.Pass), 0x00)
+			} else if this.config.ServerPubKey != "" {
+				// 用配置的公钥加密
+				encrypted, err := this.encryptPasswordByServerPubKey(scramble)
+				if err != nil {
+					return err
+				}
+				response = encrypted
+			} else {
+				// 先要服务器的公钥
+				response = []byte{cachingSha2RequestPublicKey}
+			}
+		} else {
+			// 服务器发来的RSA公钥
+			encrypted, err := encryptPassword([]byte(this.config.Pass), scramble, data)
+			if err != nil {
+				return this.LogError(err)
+			}
+			response = encrypted
+		}
+	case Sha256PasswordAuthentication:
+		// 请求公钥后，服务器发来的RSA公钥
+		encrypted, err := encryptPassword([]byte(this.config.Pass), scramble, data)
+		if err != nil {
+			return this.LogError(err)
+		}
+		response = encrypted
+	default:
+		return this.errorNotExpectedPacket(authMoreData)
+	}
+	authSwitchResponse := NewAuthSwitchResponse()
+	authSwitchResponse.AuthPluginResponse = StringEof(response)
+	writeResultRet := this.stream.Write(authSwitchResponse)
+	return writeResultRet.err
+}
+
+// 按当前的认证方法计算auth-response
+func (this *MysqlServer) authResponse(scramble []byte) ([]byte, error) {
+	if this.authenticationMethod == OldPasswordAuthentication && !this.config.AllowOldPasswords {
+		return nil, this.LogError(MysqlError{OLD_PASSWORD_DENIED, this, nil})
+	}
+	if this.authenticationMethod == Sha256PasswordAuthentication && len(this.config.Pass) > 0 {
+		if this.isSecure() {
+			// 连接已经加密，直接发送明文密码
+			return append([]byte(this.config.Pass), 0x00), nil
+		} else if this.config.ServerPubKey != "" {
+			// 配置了公钥，不用再向服务器请求
+			return this.encryptPasswordByServerPubKey(scramble)
+		}
+	}
+	if f, ok := authMethod[this.authenticationMethod]; ok && f != nil {
+		return f([]byte(this.config.Pass), scramble), nil
+	}
+	return nil, this.errorUnknownAuthenticationMethodByAuthSwitchRequest(string(this.authenticationMethod))
+}
+
+// 用Config.ServerPubKey中的公钥加密密码
+func (this *MysqlServer) encryptPasswordByServerPubKey(scramble []byte) ([]byte, error) {
+	pemData, err := os.ReadFile(this.config.ServerPubKey)
+	if err != nil {
+		return nil, this.LogError(err)
+	}
+	encrypted, err := encryptPassword([]byte(this.config.Pass), scramble, pemData)
+	if err != nil {
+		return nil, this.LogError(err)
+	}
+	return encrypted, nil
+}
+
+// 按Config.TLSMode协商TLS：服务器支持SSL时发送SSLRequest，然后把连接升级为TLS
+func (this *MysqlServer) startTLS(handshakePacket *HandshakeV10) error {
+	mode := this.config.TLSMode
+	if mode == TLSDisabled && this.config.TLSConfig != nil {
+		mode = TLSVerifyIdentity
+	}
+	if mode == TLSDisabled {
+		return nil
+	}
+	if !CapabilityFlag_CLIENT_SSL.isSet(handshakePacket.CapabilityFlags) {
+		if mode == TLSPreferred {
+			this.Log(LogWarning, fmt.Sprintf("Server=%s:%v does not support SSL, connection is not encrypted", this.config.Host, this.config.Port))
+			return nil
+		}
+		return this.LogError(TLSNotSupportedError{this.config.Host, this.config.Port})
+	}
+
+	sslRequest := NewSSLRequest(handshakePacket)
+	// this.printPacket(sslRequest)
+	writeResultRet := this.stream.Write(sslRequest)
+	if writeResultRet.err != nil {
+		return writeResultRet.err
+	}
+	// 读写goroutine这时在等待下一个请求，可以直接在连接上做TLS握手
+	tlsConn := tls.Client(this.stream.conn, this.tlsConfig(mode))
+	tlsConn.SetDeadline(deadline(this.config.DialTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return this.LogError(MysqlError{CONNECTING_FAILED, this, err})
+	}
+	tlsConn.SetDeadline(time.Time{})
+	this.stream.conn = tlsConn
+	return nil
+}
+
+// 按TLSMode生成tls.Config
+func (this *MysqlServer) tlsConfig(mode TLSModeType) *tls.Config {
+	var ret *tls.Config
+	if this.config.TLSConfig != nil {
+		ret = this.config.TLSConfig.Clone()
+	} else {
+		ret = &tls.Config{}
+	}
+	switch mode {
+	case TLSPreferred, TLSRequired:
+		ret.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// 跳过默认的校验(包括主机名)，只校验证书链
+		ret.InsecureSkipVerify = true
+		roots := ret.RootCAs
+		ret.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return Error{"Server did not send any certificate", 0}
+			}
+			opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		}
+	default:
+		if ret.ServerName == "" {
+			ret.ServerName = this.config.Host
+		}
+	}
+	return ret
+}
+
+// 连接是否已经加密。在加密的连接上可以直接发送明文密码
+func (this *MysqlServer) isSecure() bool {
+	_, ok := this.stream.conn.(*tls.Conn)
+	return ok
+}
+
+// func (this *MysqlServer) printPacket(p interface{}) {
+// 	if str, ok := p.(fmt.Stringer); ok {
+// 		fmt.Println(str)
+// 	} else {
+// 		fmt.Println(p)
+// 	}
+// }
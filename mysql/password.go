@@ -1,308 +1,180 @@
 package mysql
-// 定义在include/sha1.h
-// 定义在mysql-5.5.62/mysys/sha1.c
-// 定义在mysql-5.5.62/sql/password.c 
 
-/*
-#define SHA1_HASH_SIZE 20 // Hash size in bytes
-#include <string.h>
-#include <stdint.h>
-#include <stdlib.h>
-
-#define SCRAMBLE_LENGTH 20
-typedef uint64_t ulonglong;
-typedef uint32_t uint32;
-typedef int16_t int16;
-typedef uint8_t uint8;
-typedef char    my_bool;
-typedef unsigned char uchar;
-typedef unsigned int uint;
-typedef signed char int8;
-
-enum sha_result_codes
-{
-  SHA_SUCCESS = 0,
-  SHA_NULL,             // Null pointer parameter
-  SHA_INPUT_TOO_LONG,   // input data too long
-  SHA_STATE_ERROR       // called Input after Result
-};
-
-typedef struct SHA1_CONTEXT
-{
-  ulonglong  Length;            // Message length in bits
-  uint32 Intermediate_Hash[SHA1_HASH_SIZE/4]; // Message Digest
-  int Computed;                 // Is the digest computed?
-  int Corrupted;                // Is the message digest corrupted?
-  int16 Message_Block_Index;    // Index into message block array
-  uint8 Message_Block[64];      // 512-bit message blocks
-} SHA1_CONTEXT;
-
-const uint32 sha_const_key[5]=
-{
-  0x67452301,
-  0xEFCDAB89,
-  0x98BADCFE,
-  0x10325476,
-  0xC3D2E1F0
-};
-
-static const uint32  K[]=
-{
-  0x5A827999,
-  0x6ED9EBA1,
-  0x8F1BBCDC,
-  0xCA62C1D6
-};
-
-#define SHA1CircularShift(bits,word) \
-                (((word) << (bits)) | ((word) >> (32-(bits))))
-
-static void SHA1ProcessMessageBlock(SHA1_CONTEXT *context)
-{
-  int           t;                 // Loop counter
-  uint32        temp;              // Temporary word value
-  uint32        W[80];             // Word sequence
-  uint32        A, B, C, D, E;     // Word buffers
-  int idx;
-
-//
-//    Initialize the first 16 words in the array W
-  //
-
-  for (t = 0; t < 16; t++)
-  {
-    idx=t*4;
-    W[t] = context->Message_Block[idx] << 24;
-    W[t] |= context->Message_Block[idx + 1] << 16;
-    W[t] |= context->Message_Block[idx + 2] << 8;
-    W[t] |= context->Message_Block[idx + 3];
-  }
-
-
-  for (t = 16; t < 80; t++)
-  {
-    W[t] = SHA1CircularShift(1,W[t-3] ^ W[t-8] ^ W[t-14] ^ W[t-16]);
-  }
-
-  A = context->Intermediate_Hash[0];
-  B = context->Intermediate_Hash[1];
-  C = context->Intermediate_Hash[2];
-  D = context->Intermediate_Hash[3];
-  E = context->Intermediate_Hash[4];
-
-  for (t = 0; t < 20; t++)
-  {
-    temp= SHA1CircularShift(5,A) + ((B & C) | ((~B) & D)) + E + W[t] + K[0];
-    E = D;
-    D = C;
-    C = SHA1CircularShift(30,B);
-    B = A;
-    A = temp;
-  }
-  for (t = 20; t < 40; t++)
-  {
-    temp = SHA1CircularShift(5,A) + (B ^ C ^ D) + E + W[t] + K[1];
-    E = D;
-    D = C;
-    C = SHA1CircularShift(30,B);
-    B = A;
-    A = temp;
-  }
-
-  for (t = 40; t < 60; t++)
-  {
-    temp= (SHA1CircularShift(5,A) + ((B & C) | (B & D) | (C & D)) + E + W[t] +
-           K[2]);
-    E = D;
-    D = C;
-    C = SHA1CircularShift(30,B);
-    B = A;
-    A = temp;
-  }
-
-  for (t = 60; t < 80; t++)
-  {
-    temp = SHA1CircularShift(5,A) + (B ^ C ^ D) + E + W[t] + K[3];
-    E = D;
-    D = C;
-    C = SHA1CircularShift(30,B);
-    B = A;
-    A = temp;
-  }
-
-  context->Intermediate_Hash[0] += A;
-  context->Intermediate_Hash[1] += B;
-  context->Intermediate_Hash[2] += C;
-  context->Intermediate_Hash[3] += D;
-  context->Intermediate_Hash[4] += E;
-
-  context->Message_Block_Index = 0;
-}
-
-static void SHA1PadMessage(SHA1_CONTEXT *context)
-{
- //
- //   Check to see if the current message block is too small to hold
- //   the initial padding bits and length.  If so, we will pad the
- //   block, process it, and then continue padding into a second
- //   block.
-
-  int i=context->Message_Block_Index;
-
-  if (i > 55)
-  {
-    context->Message_Block[i++] = 0x80;
-    bzero((char*) &context->Message_Block[i],
-          sizeof(context->Message_Block[0])*(64-i));
-    context->Message_Block_Index=64;
-
-    // This function sets context->Message_Block_Index to zero
-    SHA1ProcessMessageBlock(context);
-
-    bzero((char*) &context->Message_Block[0],
-          sizeof(context->Message_Block[0])*56);
-    context->Message_Block_Index=56;
-  }
-  else
-  {
-    context->Message_Block[i++] = 0x80;
-    bzero((char*) &context->Message_Block[i],
-          sizeof(context->Message_Block[0])*(56-i));
-    context->Message_Block_Index=56;
-  }
-
-  //
-  //  Store the message length as the last 8 octets
-  //
-
-  context->Message_Block[56] = (int8) (context->Length >> 56);
-  context->Message_Block[57] = (int8) (context->Length >> 48);
-  context->Message_Block[58] = (int8) (context->Length >> 40);
-  context->Message_Block[59] = (int8) (context->Length >> 32);
-  context->Message_Block[60] = (int8) (context->Length >> 24);
-  context->Message_Block[61] = (int8) (context->Length >> 16);
-  context->Message_Block[62] = (int8) (context->Length >> 8);
-  context->Message_Block[63] = (int8) (context->Length);
-
-  SHA1ProcessMessageBlock(context);
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// 认证方法。password是用户的密码，scramble是服务器发来的auth-plugin-data，返回发给服务器的auth-response
+// 都用[]byte，scramble中可能有0x00
+
+// scramble的长度。服务器发来的auth-plugin-data最后多一个0x00
+const scrambleLength = 20
+
+// mysql_old_password，4.1之前的认证方法。scramble只用前8个字节，结果是8个字节加0x00
+// 参考mysql-5.5.62/sql/password.c的scramble_323()
+// 这种方法很不安全，需要打开Config.AllowOldPasswords才会使用
+func oldAuth(password []byte, scramble []byte) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+	return append(scramble323(password, scramble), 0x00)
 }
 
-int mysql_sha1_reset(SHA1_CONTEXT *context)
-{
-  if (!context)
-    return SHA_NULL;
-
-  context->Length                 = 0;
-  context->Message_Block_Index    = 0;
-
-  context->Intermediate_Hash[0]   = sha_const_key[0];
-  context->Intermediate_Hash[1]   = sha_const_key[1];
-  context->Intermediate_Hash[2]   = sha_const_key[2];
-  context->Intermediate_Hash[3]   = sha_const_key[3];
-  context->Intermediate_Hash[4]   = sha_const_key[4];
-
-  context->Computed   = 0;
-  context->Corrupted  = 0;
-
-  return SHA_SUCCESS;
+const scramble323Length = 8
+
+func scramble323(password []byte, scramble []byte) []byte {
+	if len(scramble) > scramble323Length {
+		scramble = scramble[:scramble323Length]
+	}
+	hashPass := hashPassword323(password)
+	hashMessage := hashPassword323(scramble)
+	rnd := newRand323(hashPass[0]^hashMessage[0], hashPass[1]^hashMessage[1])
+	ret := make([]byte, scramble323Length)
+	for i := range ret {
+		ret[i] = rnd.nextByte() + 64
+	}
+	extra := rnd.nextByte()
+	for i := range ret {
+		ret[i] ^= extra
+	}
+	return ret
 }
 
-int mysql_sha1_result(SHA1_CONTEXT *context,
-                      uint8 Message_Digest[SHA1_HASH_SIZE])
-{
-  int i;
-
-  if (!context->Computed)
-  {
-    SHA1PadMessage(context);
-    // message may be sensitive, clear it out
-    bzero((char*) context->Message_Block,64);
-    context->Length   = 0;    // and clear length
-    context->Computed = 1;
-  }
-
-  for (i = 0; i < SHA1_HASH_SIZE; i++)
-    Message_Digest[i] = (int8)((context->Intermediate_Hash[i>>2] >> 8
-                         * ( 3 - ( i & 0x03 ) )));
-  return SHA_SUCCESS;
+// 参考password.c的hash_password()，跳过空格和tab
+func hashPassword323(password []byte) [2]uint32 {
+	nr, nr2, add := uint32(1345345333), uint32(0x12345671), uint32(7)
+	for _, c := range password {
+		if c == ' ' || c == '\t' {
+			continue
+		}
+		tmp := uint32(c)
+		nr ^= (((nr & 63) + add) * tmp) + (nr << 8)
+		nr2 += (nr2 << 8) ^ nr
+		add += tmp
+	}
+	return [2]uint32{nr & 0x7FFFFFFF, nr2 & 0x7FFFFFFF}
 }
 
-int mysql_sha1_input(SHA1_CONTEXT *context, const uint8 *message_array,
-                     unsigned length)
-{
-  if (!length)
-    return SHA_SUCCESS;
-
-  while (length--)
-  {
-    context->Message_Block[context->Message_Block_Index++]=
-      (*message_array & 0xFF);
-    context->Length  += 8;  // Length is in bits
+// 参考password.c的my_rnd()
+const rand323MaxValue = 0x3FFFFFFF
 
-    if (context->Message_Block_Index == 64)
-    {
-      SHA1ProcessMessageBlock(context);
-    }
-    message_array++;
-  }
-  return SHA_SUCCESS;
+type rand323 struct {
+	seed1, seed2 uint32
 }
 
-static void
-my_crypt(char *to, const uchar *s1, const uchar *s2, uint len)
-{
-  const uint8 *s1_end= s1 + len;
-  while (s1 < s1_end)
-    *to++= *s1++ ^ *s2++;
+func newRand323(seed1, seed2 uint32) *rand323 {
+	return &rand323{seed1 % rand323MaxValue, seed2 % rand323MaxValue}
 }
-
-void
-scramble(char *to, const char *message, const char *password)
-{
-  SHA1_CONTEXT sha1_context;
-  uint8 hash_stage1[SHA1_HASH_SIZE];
-  uint8 hash_stage2[SHA1_HASH_SIZE];
-
-  mysql_sha1_reset(&sha1_context);
-  // stage 1: hash password
-  mysql_sha1_input(&sha1_context, (uint8 *) password, (uint) strlen(password));
-  mysql_sha1_result(&sha1_context, hash_stage1);
-  // stage 2: hash stage 1; note that hash_stage2 is stored in the database
-  mysql_sha1_reset(&sha1_context);
-  mysql_sha1_input(&sha1_context, hash_stage1, SHA1_HASH_SIZE);
-  mysql_sha1_result(&sha1_context, hash_stage2);
-  // create crypt string as sha1(message, hash_stage2)
-  mysql_sha1_reset(&sha1_context);
-  mysql_sha1_input(&sha1_context, (const uint8 *) message, SCRAMBLE_LENGTH);
-  mysql_sha1_input(&sha1_context, hash_stage2, SHA1_HASH_SIZE);
-  // xor allows 'from' and 'to' overlap: lets take advantage of it
-  mysql_sha1_result(&sha1_context, (uint8 *) to);
-  my_crypt(to, (const uchar *) to, hash_stage1, SCRAMBLE_LENGTH);
-}
-int32_t getSHA1_HASH_SIZE(){
-  return SHA1_HASH_SIZE;
+func (this *rand323) nextByte() byte {
+	this.seed1 = (this.seed1*3 + this.seed2) % rand323MaxValue
+	this.seed2 = (this.seed1 + this.seed2 + 33) % rand323MaxValue
+	return byte(uint64(this.seed1) * 31 / rand323MaxValue)
 }
-*/
-import "C"
-import "unsafe"
 
-func oldAuth(password string, randomData string) string{
-	return ""
-}
-func nativeAuth(password string, randomData string) string{
-	passwordPtr, randomDataPtr := C.CString(password), C.CString(randomData)
-	dst := string(make([]byte, 128))
-	dstPtr := C.CString(dst)
-	defer C.free(unsafe.Pointer(passwordPtr))
-	defer C.free(unsafe.Pointer(randomDataPtr))
-	defer C.free(unsafe.Pointer(dstPtr))
-	C.scramble(dstPtr, randomDataPtr, passwordPtr)
-	ret := C.GoString(dstPtr)
+// mysql_native_password
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+// 参考mysql-5.5.62/sql/password.c的scramble()
+func nativeAuth(password []byte, scramble []byte) []byte {
+	// 密码为空时auth-response也为空
+	if len(password) == 0 {
+		return nil
+	}
+	if len(scramble) > scrambleLength {
+		scramble = scramble[:scrambleLength]
+	}
+	// stage 1: hash password
+	hashStage1 := sha1.Sum(password)
+	// stage 2: hash stage 1; note that hash_stage2 is stored in the database
+	hashStage2 := sha1.Sum(hashStage1[:])
+	// create crypt string as sha1(message, hash_stage2)
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(hashStage2[:])
+	ret := h.Sum(nil)
+	for i := range ret {
+		ret[i] ^= hashStage1[i]
+	}
 	return ret
 }
+
 // 例：
 // {Type:AuthSwitchRequest, Version:254, PluginName:mysql_native_password, AuthPluginData:_NGXckqspXG:.v)d>J:"}
 // 密码=123456
-func clearAuth(password string, _ string) string{
+func clearAuth(password []byte, _ []byte) []byte {
 	return password
 }
+
+// caching_sha2_password的快速认证
+// XOR(SHA256(password), SHA256(SHA256(SHA256(password)), scramble))
+func cachingSha2Auth(password []byte, scramble []byte) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+	if len(scramble) > scrambleLength {
+		scramble = scramble[:scrambleLength]
+	}
+	message1 := sha256.Sum256(password)
+	message1Hash := sha256.Sum256(message1[:])
+	h := sha256.New()
+	h.Write(message1Hash[:])
+	h.Write(scramble)
+	ret := h.Sum(nil)
+	for i := range ret {
+		ret[i] ^= message1[i]
+	}
+	return ret
+}
+
+// caching_sha2_password/sha256_password在AuthMoreData中用到的数据
+const (
+	sha256RequestPublicKey      byte = 0x01 // C->S sha256_password请求服务器的RSA公钥
+	cachingSha2RequestPublicKey byte = 0x02 // C->S caching_sha2_password请求服务器的RSA公钥
+	cachingSha2FastAuthSuccess  byte = 0x03 // S->C 快速认证成功，后面是OK
+	cachingSha2PerformFullAuth  byte = 0x04 // S->C 服务器的缓存里没有这个用户，需要完整认证
+)
+
+// sha256_password
+// 不能直接发送密码，先请求服务器的RSA公钥，收到后再用encryptPassword加密。
+// 连接已加密或配置了公钥时不走这里，见MysqlServer.authResponse
+func sha256Auth(password []byte, _ []byte) []byte {
+	if len(password) == 0 {
+		// 空密码
+		return []byte{0x00}
+	}
+	return []byte{sha256RequestPublicKey}
+}
+
+// 用服务器的RSA公钥加密密码：密码末尾加0，与scramble循环异或后，用RSA-OAEP(SHA1)加密
+func encryptPassword(password []byte, scramble []byte, pemData []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, Error{fmt.Sprintf("Invalid RSA public key %v", string(pemData)), 0}
+	}
+	var publicKey *rsa.PublicKey
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			publicKey = rsaKey
+		}
+	} else if rsaKey, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		publicKey = rsaKey
+	}
+	if publicKey == nil {
+		return nil, Error{fmt.Sprintf("Invalid RSA public key %v", string(pemData)), 0}
+	}
+	if len(scramble) > scrambleLength {
+		scramble = scramble[:scrambleLength]
+	}
+	plain := append(append([]byte{}, password...), 0x00)
+	if len(scramble) > 0 {
+		for i := range plain {
+			plain[i] ^= scramble[i%len(scramble)]
+		}
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, publicKey, plain, nil)
+}
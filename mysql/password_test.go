@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestNativeAuth(t *testing.T) {
+	// S->C  4e 00 00 00 0a 35 2e 35 2e 36 32 2d 6c 6f 67 00  47 00 00 00 32 47 52 43 3c 41 73 3e 00 ff f7 08    N....5.5.62-log. G...2GRC<As>....
+	//       02 00 0f 80 15 00 00 00 00 00 00 00 00 00 00 7a  6d 35 5d 58 7e 34 28 66 4d 2c 36 00 6d 79 73 71    ...............z m5]X~4(fM,6.mysq
+	//       6c 5f 6e 61 74 69 76 65 5f 70 61 73 73 77 6f 72  64 00                                              l_native_passwor d.
+	// flags = low=ff f7 high=0f 80, CLIENT_PLUGIN_AUTH=1, Length of auth-plugin-data=0x15, CLIENT_SECURE_CONNECTION=1,
+	// auth-plugin-data-part-1 = 32 47 52 43 3c 41 73 3e
+	// len of auth-plugin-data-part-2 = max(13, 0x15-8) = 13, auth-plugin-data-part-2 = 7a  6d 35 5d 58 7e 34 28 66 4d 2c 36 00
+	// C->S  55 00 00 01 85 a6 7f 00 00 00 00 01 21 00 00 00  00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00    U...........!... ................
+	//       00 00 00 00 74 65 73 74 5f 75 73 65 72 00 14 b1  dc 18 f5 10 f1 c9 9f 79 8f 3e 46 34 74 40 91 bd    ....test_user... .......y.>F4t@..
+	//       a3 b4 37 6d 79 73 71 6c 5f 6e 61 74 69 76 65 5f  70 61 73 73 77 6f 72 64 00                         ..7mysql_native_ password.
+	// flags = 85 a6 7f 00, LENENC_CLIENT_DATA = 1, length of auth-response=0x14, auth response=b1  dc 18 f5 10 f1 c9 9f 79 8f 3e 46 34 74 40 91 bd a3 b4 37
+	scramble := []byte{0x32, 0x47, 0x52, 0x43, 0x3c, 0x41, 0x73, 0x3e, 0x7a, 0x6d, 0x35, 0x5d, 0x58, 0x7e, 0x34, 0x28, 0x66, 0x4d, 0x2c, 0x36}
+	expected := []byte{0xb1, 0xdc, 0x18, 0xf5, 0x10, 0xf1, 0xc9, 0x9f, 0x79, 0x8f, 0x3e, 0x46, 0x34, 0x74, 0x40, 0x91, 0xbd, 0xa3, 0xb4, 0x37}
+	if r := nativeAuth([]byte("123456"), scramble); !bytes.Equal(r, expected) {
+		t.Errorf("nativeAuth=% x, expected=% x", r, expected)
+	}
+	// auth-plugin-data-part-2最后的0x00不参与计算
+	if r := nativeAuth([]byte("123456"), append(scramble, 0x00)); !bytes.Equal(r, expected) {
+		t.Errorf("nativeAuth with trailing NUL=% x, expected=% x", r, expected)
+	}
+	if r := nativeAuth(nil, scramble); len(r) != 0 {
+		t.Errorf("nativeAuth with empty password=% x", r)
+	}
+}
+
+func TestScramble323(t *testing.T) {
+	// 与go-sql-driver/mysql的测试数据相同
+	scramble := []byte{9, 8, 7, 6, 5, 4, 3, 2}
+	cases := []struct {
+		password string
+		expected string
+	}{
+		{" pass", "47575c5a435b4251"},
+		{"pass ", "47575c5a435b4251"}, // 空格被忽略
+		{"123\t456", "575c47505b5b5559"},
+		{"C0mpl!ca ted#PASS123", "5d5d554849584a45"},
+	}
+	for _, c := range cases {
+		if r := hex.EncodeToString(scramble323([]byte(c.password), scramble)); r != c.expected {
+			t.Error("password=", c.password, " out=", r, " expected=", c.expected)
+		}
+	}
+	// 只使用scramble的前8个字节，结果末尾是0x00
+	if r := oldAuth([]byte(" pass"), append(scramble, 1, 2, 3)); hex.EncodeToString(r) != "47575c5a435b425100" {
+		t.Errorf("oldAuth=% x", r)
+	}
+	if r := oldAuth(nil, scramble); len(r) != 0 {
+		t.Errorf("oldAuth with empty password=% x", r)
+	}
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import "testing"
+
+// 在另一个goroutine里执行query，返回结果
+func queryAsync(server *MysqlServer, sql string) (chan ResultSetType, chan error) {
+	result := make(chan ResultSetType, 1)
+	errs := make(chan error, 1)
+	go func() {
+		resultSet, err := server.query(sql)
+		result <- resultSet
+		errs <- err
+	}()
+	return result, errs
+}
+
+func TestQueryResultSet(t *testing.T) {
+	// 没有CLIENT_DEPRECATE_EOF时列定义和行之后是EOF，有时行之后是0xFE开头的OK
+	for _, deprecateEOF := range []bool{false, true} {
+		fake, server := newFakeServer(t, Config{User: "repl"})
+		if deprecateEOF {
+			fake.flags |= Uint4(CapabilityFlag_CLIENT_DEPRECATE_EOF)
+		}
+		opened := openAsync(server)
+		fake.acceptHandshake("8.0.30")
+		checkOpened(t, server, opened)
+		if CapabilityFlag_CLIENT_DEPRECATE_EOF.isSet(fake.clientFlags) != deprecateEOF {
+			t.Errorf("client flags=%x", fake.clientFlags)
+		}
+
+		result, errs := queryAsync(server, "SHOW BINARY LOGS")
+		if com := fake.readPacket(0); string(com) != "\x03SHOW BINARY LOGS" {
+			t.Errorf("COM_QUERY=%q", com)
+		}
+		fake.writeResultSetRows(1, []string{"Log_name", "File_size"}, [][]string{{"binlog.000001", "180"}, {"binlog.000002", ""}})
+		resultSet, err := <-result, <-errs
+		if err != nil {
+			t.Fatal("deprecateEOF=", deprecateEOF, " query:", err)
+		}
+		if len(resultSet.Columns) != 2 || len(resultSet.Rows) != 2 {
+			t.Fatal("deprecateEOF=", deprecateEOF, " resultSet=", resultSet)
+		}
+		if value, ok := resultSet.GetString(1, "Log_name"); !ok || value != "binlog.000002" {
+			t.Error("deprecateEOF=", deprecateEOF, " Log_name=", value)
+		}
+		if value, ok := resultSet.GetString(0, "File_size"); !ok || value != "180" {
+			t.Error("deprecateEOF=", deprecateEOF, " File_size=", value)
+		}
+
+		// 没有行的结果集
+		result, errs = queryAsync(server, "SHOW BINARY LOGS")
+		fake.readPacket(0)
+		fake.writeResultSetRows(1, []string{"Log_name", "File_size"}, nil)
+		if resultSet, err = <-result, <-errs; err != nil || len(resultSet.Columns) != 2 || len(resultSet.Rows) != 0 {
+			t.Error("deprecateEOF=", deprecateEOF, " resultSet=", resultSet, err)
+		}
+		closeFakeServer(t, fake, server)
+	}
+}
+
+func TestMasterStatus(t *testing.T) {
+	cases := []struct {
+		version string
+		sql     string
+	}{
+		{"5.7.40-log", "SHOW MASTER STATUS"},
+		{"8.0.30", "SHOW MASTER STATUS"},
+		{"8.2.0", "SHOW BINARY LOG STATUS"},
+		{"8.4.2", "SHOW BINARY LOG STATUS"},
+		{"11.4.2-MariaDB-log", "SHOW MASTER STATUS"},
+	}
+	for _, c := range cases {
+		fake, server := openFakeServer(t, Config{User: "repl"}, c.version)
+		result := make(chan error, 1)
+		var binlog BinglogType
+		go func() {
+			var err error
+			binlog, err = server.masterStatus()
+			result <- err
+		}()
+		if com := fake.readPacket(0); string(com[1:]) != c.sql {
+			t.Errorf("version=%v COM_QUERY=%q", c.version, com)
+		}
+		fake.writeResultSet(1, []string{"File", "Position", "Binlog_Do_DB"}, []string{"binlog.000003", "1234", ""})
+		if err := <-result; err != nil || binlog != (BinglogType{"binlog.000003", 1234}) {
+			t.Error("version=", c.version, " binlog=", binlog, err)
+		}
+
+		// 没有打开binlog时是空的结果集
+		go func() {
+			_, err := server.masterStatus()
+			result <- err
+		}()
+		fake.readPacket(0)
+		fake.writeResultSetRows(1, []string{"File", "Position"}, nil)
+		if err, ok := (<-result).(MysqlError); !ok || err.Code != BINLOG_DISABLED {
+			t.Error("version=", c.version, " err=", err)
+		}
+		closeFakeServer(t, fake, server)
+	}
+}
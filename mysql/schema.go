@@ -0,0 +1,132 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 另开一个连接查询information_schema.COLUMNS，不影响复制用的连接
+// 连接在第一次查询时建立，查询出错后关闭，下次查询时重新连接
+type InformationSchemaLoader struct {
+	mutex  sync.Mutex
+	config Config
+	log    Log
+	server *MysqlServer
+}
+
+func NewInformationSchemaLoader(config Config, log Log) *InformationSchemaLoader {
+	config.LoadSchema = false
+	config.SchemaLoader = nil
+	config.Reconnect = false
+	return &InformationSchemaLoader{config: config, log: log}
+}
+func (this *InformationSchemaLoader) LoadColumns(schema, table string) (TableAttr, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.server == nil {
+		server := NewMysqlServer(this.config, nil, this.log)
+		if err := server.Connect(); err != nil {
+			return nil, err
+		}
+		if err := server.Open(); err != nil {
+			server.Close()
+			return nil, err
+		}
+		this.server = server
+	}
+	sql := fmt.Sprintf("SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=%s AND TABLE_NAME=%s ORDER BY ORDINAL_POSITION", quoteString(schema), quoteString(table))
+	resultSet, err := this.server.query(sql)
+	if err != nil {
+		// 连接可能已经断开，下次重新连接
+		this.server.Close()
+		this.server = nil
+		return nil, err
+	}
+	if len(resultSet.Rows) == 0 {
+		return nil, nil
+	}
+	ret := make(TableAttr, len(resultSet.Rows))
+	for row := range resultSet.Rows {
+		name, _ := resultSet.GetString(row, "COLUMN_NAME")
+		dataType, _ := resultSet.GetString(row, "DATA_TYPE")
+		columnType, _ := resultSet.GetString(row, "COLUMN_TYPE")
+		ret[row] = newColumnAttrByColumnType(name, dataType, columnType)
+	}
+	return ret, nil
+}
+
+// 关闭查询用的连接
+func (this *InformationSchemaLoader) Close() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.server == nil {
+		return nil
+	}
+	err := this.server.Close()
+	this.server = nil
+	return err
+}
+
+// 固定的表结构，主要用于测试
+func (this SchemaAttr) LoadColumns(schema, table string) (TableAttr, error) {
+	if tableAttr, ok := this[schema][table]; ok {
+		return append(TableAttr{}, tableAttr...), nil
+	}
+	return nil, nil
+}
+
+// 由information_schema.COLUMNS中的DATA_TYPE(如int)和COLUMN_TYPE(如int(10) unsigned、set('a','b'))生成列的属性
+func newColumnAttrByColumnType(name, dataType, columnType string) ColumnAttr {
+	columnAttr := ColumnAttr{}
+	columnAttr.Name = name
+	columnAttr.Type = strings.ToLower(dataType)
+	if columnAttr.Type == "set" || columnAttr.Type == "enum" {
+		columnAttr.SetTypeValues = parseSetTypeValues(columnType)
+	} else {
+		columnAttr.Unsigned = strings.Contains(strings.ToLower(columnType), "unsigned")
+	}
+	return columnAttr
+}
+
+// 解析set类型中的各个值，值中的单引号写作两个单引号，如
+//
+//	set('a','b''c')
+func parseSetTypeValues(columnType string) []string {
+	ret := make([]string, 0)
+	start := strings.IndexByte(columnType, '(')
+	if start < 0 {
+		return ret
+	}
+	var value []byte
+	quoted := false
+	for i := start + 1; i < len(columnType); i++ {
+		c := columnType[i]
+		if !quoted {
+			if c == '\'' {
+				quoted = true
+				value = value[:0]
+			} else if c == ')' {
+				break
+			}
+		} else if c == '\'' {
+			if i+1 < len(columnType) && columnType[i+1] == '\'' {
+				value = append(value, c)
+				i++
+			} else {
+				quoted = false
+				ret = append(ret, string(value))
+			}
+		} else {
+			value = append(value, c)
+		}
+	}
+	return ret
+}
+
+// 生成SQL中的字符串常量
+func quoteString(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "'", "''", -1)
+	return "'" + s + "'"
+}
@@ -0,0 +1,169 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSetTypeValues(t *testing.T) {
+	values := parseSetTypeValues("set('a','B,c','it''s','')")
+	expected := []string{"a", "B,c", "it's", ""}
+	if len(values) != len(expected) {
+		t.Fatal("values=", values)
+	}
+	for idx := range expected {
+		if values[idx] != expected[idx] {
+			t.Errorf("value %d=%q expected=%q", idx, values[idx], expected[idx])
+		}
+	}
+
+	if columnAttr := newColumnAttrByColumnType("id", "INT", "int(10) UNSIGNED"); columnAttr.Type != "int" || !columnAttr.Unsigned {
+		t.Error("columnAttr=", columnAttr)
+	}
+	if quoted := quoteString(`a'b\c`); quoted != `'a''b\\c'` {
+		t.Error("quoted=", quoted)
+	}
+}
+
+func TestInformationSchemaLoader(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	serverConn.SetDeadline(time.Now().Add(5 * time.Second))
+	config := *NewConfig()
+	config.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return clientConn, nil
+	}
+	loader := NewInformationSchemaLoader(config, testLog{t})
+	defer loader.Close()
+	defer serverConn.Close()
+	fake := newFakeServerConn(t, serverConn)
+	go func() {
+		fake.acceptHandshake("8.0.30")
+		query := string(fake.readPacket(0))
+		if !strings.Contains(query, "TABLE_SCHEMA='db' AND TABLE_NAME='t'") {
+			t.Error("query=", query)
+		}
+		fake.writeResultSetRows(1, []string{"COLUMN_NAME", "DATA_TYPE", "COLUMN_TYPE"}, [][]string{
+			{"id", "int", "int unsigned"},
+			{"name", "varchar", "varchar(20)"},
+			{"tags", "set", "set('a','b')"},
+		})
+	}()
+	tableAttr, err := loader.LoadColumns("db", "t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tableAttr) != 3 || tableAttr[0].Name != "id" || !tableAttr[0].Unsigned || tableAttr[1].Unsigned || len(tableAttr[2].SetTypeValues) != 2 {
+		t.Error("tableAttr=", tableAttr)
+	}
+}
+
+// 记录OnColumnAttr的回调
+type columnAttrCallback struct {
+	testCallback
+	missing []string
+}
+
+func (this *columnAttrCallback) OnColumnAttr(schema, table string, colIdx int) {
+	this.missing = append(this.missing, schema+"."+table)
+}
+
+// 记录查询次数
+type countingLoader struct {
+	SchemaAttr
+	count int
+}
+
+func (this *countingLoader) LoadColumns(schema, table string) (TableAttr, error) {
+	this.count++
+	return this.SchemaAttr.LoadColumns(schema, table)
+}
+
+func testTableMap(schema, table string, columnCount int) TableMapEventType {
+	tableMap := NewTableMapEvent()
+	tableMap.SchemaName = StringFix(schema)
+	tableMap.TableName = StringFix(table)
+	tableMap.ColumnDef = make([]ColumnType, columnCount)
+	return tableMap
+}
+
+func TestLoadColumns(t *testing.T) {
+	loader := &countingLoader{SchemaAttr: SchemaAttr{"db": TableAttrs{
+		"t": TableAttr{{Name: "id", Type: "int", Unsigned: true}, {Name: "name", Type: "varchar"}},
+	}}}
+	config := *NewConfig()
+	config.LoadSchema = true
+	config.SchemaLoader = loader
+	server := NewMysqlServer(config, nil, testLog{t})
+	callback := &columnAttrCallback{}
+
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	if column := server.serverConfig.getColumnAt("db", "t", 0); column == nil || column.Name != "id" || !column.Unsigned {
+		t.Error("column=", column)
+	}
+	// 已经缓存，不再查询
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	if loader.count != 1 || len(callback.missing) != 0 {
+		t.Error("count=", loader.count, " missing=", callback.missing)
+	}
+
+	// 查询不到时回调，之后不再查询，也不再回调
+	server.loadColumns(callback, testTableMap("db", "x", 1))
+	server.loadColumns(callback, testTableMap("db", "x", 1))
+	if loader.count != 2 || len(callback.missing) != 1 || callback.missing[0] != "db.x" {
+		t.Error("count=", loader.count, " missing=", callback.missing)
+	}
+
+	// 列数与TABLE_MAP不同时重新查询
+	loader.SchemaAttr["db"]["t"] = append(loader.SchemaAttr["db"]["t"], ColumnAttr{Name: "score", Type: "tinyint"})
+	server.loadColumns(callback, testTableMap("db", "t", 3))
+	if column := server.serverConfig.getColumnAt("db", "t", 2); column == nil || column.Name != "score" {
+		t.Error("column=", column)
+	}
+
+	// 没有打开LoadSchema时只回调，每个表一次
+	server = NewMysqlServer(*NewConfig(), nil, testLog{t})
+	callback = &columnAttrCallback{}
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	if len(callback.missing) != 1 || server.serverConfig.getColumnAt("db", "t", 0) != nil {
+		t.Error("missing=", callback.missing)
+	}
+}
+
+// 查询总是失败
+type failingLoader struct {
+	count int
+}
+
+func (this *failingLoader) LoadColumns(schema, table string) (TableAttr, error) {
+	this.count++
+	return nil, errors.New("connection refused")
+}
+
+func TestLoadColumnsFailed(t *testing.T) {
+	loader := &failingLoader{}
+	config := *NewConfig()
+	config.LoadSchema = true
+	config.SchemaLoader = loader
+	server := NewMysqlServer(config, nil, testLog{t})
+	callback := &columnAttrCallback{}
+
+	// 失败后一段时间内不再查询，其它表也一样
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	server.loadColumns(callback, testTableMap("db", "x", 1))
+	if loader.count != 1 || len(callback.missing) != 2 {
+		t.Error("count=", loader.count, " missing=", callback.missing)
+	}
+
+	// 到时间后重新查询
+	server.schemaLoadRetry = time.Now()
+	server.loadColumns(callback, testTableMap("db", "t", 2))
+	if loader.count != 2 || len(callback.missing) != 2 {
+		t.Error("count=", loader.count, " missing=", callback.missing)
+	}
+}
@@ -0,0 +1,131 @@
+package mysql
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 把断点以json格式保存在文件中
+// 先写临时文件并fsync，再rename覆盖原文件，最后fsync目录。中途崩溃时文件要么是旧的，要么是新的
+type FileStorage struct {
+	path string
+}
+
+func NewFileStorage(path string) *FileStorage {
+	return &FileStorage{path}
+}
+func (this *FileStorage) Load() (*Checkpoint, error) {
+	buf, err := os.ReadFile(this.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ret := &Checkpoint{}
+	if err = json.Unmarshal(buf, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+func (this *FileStorage) Save(checkpoint Checkpoint) error {
+	buf, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmpPath := this.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err = os.Rename(tmpPath, this.path); err != nil {
+		return err
+	}
+	// rename本身要落盘，需要fsync所在的目录
+	dir, err := os.Open(filepath.Dir(this.path))
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// 兼容旧版本保存的断点，那时是否UNSIGNED保存在Signed中
+func (this *ColumnAttr) UnmarshalJSON(data []byte) error {
+	type columnAttr ColumnAttr
+	attr := struct {
+		columnAttr
+		Signed *bool
+	}{}
+	if err := json.Unmarshal(data, &attr); err != nil {
+		return err
+	}
+	*this = ColumnAttr(attr.columnAttr)
+	if attr.Signed != nil {
+		this.Unsigned = *attr.Signed
+	}
+	return nil
+}
+
+// 保存在内存中的断点，进程退出后就没有了。主要用于测试
+type MemoryStorage struct {
+	mutex      sync.Mutex
+	checkpoint *Checkpoint
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+func (this *MemoryStorage) Load() (*Checkpoint, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.checkpoint == nil {
+		return nil, nil
+	}
+	ret := this.checkpoint.copy()
+	return &ret, nil
+}
+func (this *MemoryStorage) Save(checkpoint Checkpoint) error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	saved := checkpoint.copy()
+	this.checkpoint = &saved
+	return nil
+}
+
+// 深拷贝。Columns在复制过程中还会被修改，保存的必须是当时的快照
+func (this Checkpoint) copy() Checkpoint {
+	ret := this
+	if this.Columns != nil {
+		ret.Columns = make(SchemaAttr, len(this.Columns))
+		for schema, tableAttrs := range this.Columns {
+			ret.Columns[schema] = make(TableAttrs, len(tableAttrs))
+			for table, tableAttr := range tableAttrs {
+				columns := make(TableAttr, len(tableAttr))
+				for idx, columnAttr := range tableAttr {
+					columns[idx] = columnAttr
+					if columnAttr.SetTypeValues != nil {
+						columns[idx].SetTypeValues = append([]string{}, columnAttr.SetTypeValues...)
+					}
+				}
+				ret.Columns[schema][table] = columns
+			}
+		}
+	}
+	return ret
+}
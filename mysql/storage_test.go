@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testCheckpoint() Checkpoint {
+	checkpoint := Checkpoint{}
+	checkpoint.Binlog = BinglogType{"binlog.000003", 1234}
+	checkpoint.GTIDSet = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"
+	checkpoint.Columns = SchemaAttr{"test": TableAttrs{"tbl_set": TableAttr{
+		ColumnAttr{Name: "id", Type: "int"},
+		ColumnAttr{Name: "val", Type: "set", SetTypeValues: []string{"a", "b"}},
+	}}}
+	return checkpoint
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := os.MkdirTemp("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+	storage := NewFileStorage(path)
+
+	// 没有保存过
+	if checkpoint, err := storage.Load(); checkpoint != nil || err != nil {
+		t.Error("Load before Save, checkpoint=", checkpoint, " err=", err)
+	}
+
+	expected := testCheckpoint()
+	if err := storage.Save(expected); err != nil {
+		t.Fatal(err)
+	}
+	expected.Binlog.BinlogPos = 4567
+	if err := storage.Save(expected); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("tmp file left, err=", err)
+	}
+
+	// 另开一个实例读，模拟重启
+	checkpoint, err := NewFileStorage(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || !reflect.DeepEqual(*checkpoint, expected) {
+		t.Error("checkpoint=", checkpoint, " expected=", expected)
+	}
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storage := NewMemoryStorage()
+	if checkpoint, err := storage.Load(); checkpoint != nil || err != nil {
+		t.Error("Load before Save, checkpoint=", checkpoint, " err=", err)
+	}
+
+	saved := testCheckpoint()
+	if err := storage.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	// 保存之后再修改表结构，不能影响已经保存的断点
+	saved.Columns["test"]["tbl_set"][1].SetTypeValues[0] = "x"
+	saved.Columns["test"]["tbl_new"] = TableAttr{}
+
+	checkpoint, err := storage.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil || !reflect.DeepEqual(*checkpoint, testCheckpoint()) {
+		t.Error("checkpoint=", checkpoint, " expected=", testCheckpoint())
+	}
+}
+
+// 旧版本保存的断点中，是否UNSIGNED保存在Signed中
+func TestFileStorageLegacyCheckpoint(t *testing.T) {
+	dir, err := os.MkdirTemp("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+	legacy := `{"Binlog":{"Filename":"binlog.000003","BinlogPos":1234},"GTIDSet":"","Columns":{"test":{"tbl":[` +
+		`{"Name":"id","Type":"int","SetTypeValues":null,"Signed":true},` +
+		`{"Name":"val","Type":"int","SetTypeValues":null,"Signed":false}]}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := NewFileStorage(path).Load()
+	if err != nil || checkpoint == nil {
+		t.Fatal("checkpoint=", checkpoint, " err=", err)
+	}
+	expected := TableAttr{ColumnAttr{Name: "id", Type: "int", Unsigned: true}, ColumnAttr{Name: "val", Type: "int"}}
+	if tableAttr := checkpoint.Columns["test"]["tbl"]; !reflect.DeepEqual(tableAttr, expected) {
+		t.Error("columns=", tableAttr)
+	}
+
+	// 保存后是新的格式，再读也一样
+	if err := NewFileStorage(path).Save(*checkpoint); err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint, err = NewFileStorage(path).Load(); err != nil || !reflect.DeepEqual(checkpoint.Columns["test"]["tbl"], expected) {
+		t.Error("checkpoint=", checkpoint, " err=", err)
+	}
+}
@@ -0,0 +1,210 @@
+package mysql
+
+import (
+	"math"
+	"net"
+	"testing"
+)
+
+// 表db.t：id INT UNSIGNED PRIMARY KEY, name VARCHAR(20), tags SET('a','b'), score TINYINT UNSIGNED
+func testTableMapEvent(metadata []byte) []byte {
+	columnTypes := []ColumnType{ColumnTypeLong, ColumnTypeVarchar, ColumnTypeString, ColumnTypeTiny}
+	return testTableMapEventWith(columnTypes, []byte{0x50, 0x00, byte(ColumnTypeSet), 0x01}, metadata)
+}
+
+// table id=0x11的表db.t，最多8列
+func testTableMapEventWith(columnTypes []ColumnType, columnMetaDef []byte, metadata []byte) []byte {
+	body := []byte{0x11, 0, 0, 0, 0, 0, 0x01, 0x00}
+	body = append(body, 2, 'd', 'b', 0, 1, 't', 0)
+	body = append(body, byte(len(columnTypes)))
+	for _, columnType := range columnTypes {
+		body = append(body, byte(columnType))
+	}
+	body = append(body, byte(len(columnMetaDef)))
+	body = append(body, columnMetaDef...)
+	body = append(body, 0x00)
+	body = append(body, metadata...)
+	return testEventPacket(EventTypeTableMapEvent, body)
+}
+
+func testTableMapMetadata() []byte {
+	metadata := []byte{byte(TableMapMetadataSignedness), 1, 0xc0}
+	metadata = append(metadata, byte(TableMapMetadataDefaultCharset), 3, 0xfc, 0xff, 0x00) // utf8mb4_0900_ai_ci
+	metadata = append(metadata, byte(TableMapMetadataColumnName), 19, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e', 4, 't', 'a', 'g', 's', 5, 's', 'c', 'o', 'r', 'e')
+	metadata = append(metadata, byte(TableMapMetadataSetStrValue), 5, 2, 1, 'a', 1, 'b')
+	metadata = append(metadata, byte(TableMapMetadataSimplePrimaryKey), 1, 0)
+	// 不认识的类型跳过
+	metadata = append(metadata, 99, 2, 1, 2)
+	return metadata
+}
+
+// 依次读出pkts中的event，多个event共用同一个serverConfig
+func readTestEvents(t *testing.T, pkts ...[]byte) (*ServerConfigType, []interface{}) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	go func() {
+		for _, pkt := range pkts {
+			server.Write(pkt)
+		}
+	}()
+	stream := NewMysqlStream(client)
+	stream.log = testLog{t}
+	stream.config = NewConfig()
+	stream.serverConfig = NewServerConfig()
+	stream.serverConfig.Version = "8.0.30"
+	stream.serverConfig.BinlogVersion = 4
+	stream.serverConfig.CrcSize = 4
+	// post-header长度不是6，table id占6个字节
+	stream.serverConfig.EventTypeHeaderLength = make([]byte, EventTypeWriteRowsEventv2)
+	events := make([]interface{}, 0, len(pkts))
+	for range pkts {
+		fullEvent, err := stream.ReadEvent()
+		if err != nil {
+			t.Fatal(err)
+		}
+		event := fullEvent.(FullEvent).event
+		if tableMap, ok := event.(TableMapEventType); ok {
+			stream.serverConfig.TableMaps[Uint8(tableMap.TableId)] = tableMap
+			stream.serverConfig.applyTableMap(tableMap)
+		}
+		events = append(events, event)
+	}
+	return stream.serverConfig, events
+}
+
+func TestTableMapOptionalMetadata(t *testing.T) {
+	_, events := readTestEvents(t, testTableMapEvent(testTableMapMetadata()), testTableMapEvent(nil))
+	tableMap := events[0].(TableMapEventType)
+	meta := tableMap.OptionalMetadata
+	if len(meta.Unsigned) != 4 || !meta.Unsigned[0] || meta.Unsigned[1] || meta.Unsigned[2] || !meta.Unsigned[3] {
+		t.Error("Unsigned=", meta.Unsigned)
+	}
+	if len(meta.Charsets) != 4 || meta.Charsets[1] != 255 || meta.Charsets[0] != 0 {
+		t.Error("Charsets=", meta.Charsets)
+	}
+	if len(meta.ColumnNames) != 4 || meta.ColumnNames[3] != "score" {
+		t.Error("ColumnNames=", meta.ColumnNames)
+	}
+	if len(meta.SetTypeValues) != 4 || len(meta.SetTypeValues[2]) != 2 || meta.SetTypeValues[2][1] != "b" {
+		t.Error("SetTypeValues=", meta.SetTypeValues)
+	}
+	if len(meta.PrimaryKey) != 1 || meta.PrimaryKey[0] != 0 {
+		t.Error("PrimaryKey=", meta.PrimaryKey)
+	}
+
+	// 5.7及之前没有可选元数据
+	tableMap = events[1].(TableMapEventType)
+	if tableMap.OptionalMetadata.ColumnNames != nil || tableMap.OptionalMetadata.Unsigned != nil {
+		t.Error("OptionalMetadata=", tableMap.OptionalMetadata)
+	}
+}
+
+func TestApplyTableMap(t *testing.T) {
+	rows := []byte{0x11, 0, 0, 0, 0, 0, 0x01, 0x00, 0x02, 0x00, 4, 0x0f}
+	rows = append(rows, 0x00, 0x01, 0x00, 0x00, 0x00, 3, 'a', 'b', 'c', 0x02, 200)
+	serverConfig, events := readTestEvents(t, testTableMapEvent(testTableMapMetadata()), testEventPacket(EventTypeWriteRowsEventv2, rows))
+	tableAttr := serverConfig.Columns["db"]["t"]
+	expected := []ColumnAttr{{"id", "int", nil, true}, {"name", "varchar", nil, false}, {"tags", "set", []string{"a", "b"}, false}, {"score", "tinyint", nil, true}}
+	if len(tableAttr) != len(expected) {
+		t.Fatal("Columns=", tableAttr)
+	}
+	for idx := range expected {
+		if tableAttr[idx].Name != expected[idx].Name || tableAttr[idx].Type != expected[idx].Type || tableAttr[idx].Unsigned != expected[idx].Unsigned || len(tableAttr[idx].SetTypeValues) != len(expected[idx].SetTypeValues) {
+			t.Errorf("column %d=%v expected=%v", idx, tableAttr[idx], expected[idx])
+		}
+	}
+
+	// TINYINT UNSIGNED按元数据中的符号读
+	rowsEvent := events[1].(RowsEventType)
+	if len(rowsEvent.Rows) != 1 || len(rowsEvent.Rows[0].Value1) != 4 {
+		t.Fatal("rows=", rowsEvent)
+	}
+	if score, ok := rowsEvent.Rows[0].Value1[3].value.(uint8); !ok || score != 200 {
+		t.Error("score=", rowsEvent.Rows[0].Value1[3])
+	}
+}
+
+// binlog_row_metadata=MINIMAL时只有符号，没有DDL也按它读
+func TestTableMapSignednessOnly(t *testing.T) {
+	metadata := []byte{byte(TableMapMetadataSignedness), 1, 0xc0}
+	rows := []byte{0x11, 0, 0, 0, 0, 0, 0x01, 0x00, 0x02, 0x00, 4, 0x0f}
+	rows = append(rows, 0x00, 0xff, 0xff, 0xff, 0xff, 3, 'a', 'b', 'c', 0x02, 200)
+	serverConfig, events := readTestEvents(t, testTableMapEvent(metadata), testEventPacket(EventTypeWriteRowsEventv2, rows))
+	if tableAttr, ok := serverConfig.Columns["db"]["t"]; ok {
+		t.Error("Columns=", tableAttr)
+	}
+	values := events[1].(RowsEventType).Rows[0].Value1
+	if id, ok := values[0].value.(uint32); !ok || id != math.MaxUint32 {
+		t.Error("id=", values[0])
+	}
+	if score, ok := values[3].value.(uint8); !ok || score != 200 {
+		t.Error("score=", values[3])
+	}
+}
+
+func TestUnsignedColumnValues(t *testing.T) {
+	columnTypes := []ColumnType{ColumnTypeTiny, ColumnTypeShort, ColumnTypeInt24, ColumnTypeLong, ColumnTypeLonglong}
+	// 第1、3、5列UNSIGNED
+	metadata := []byte{byte(TableMapMetadataSignedness), 1, 0xa8}
+	metadata = append(metadata, byte(TableMapMetadataColumnName), 10, 1, 'a', 1, 'b', 1, 'c', 1, 'd', 1, 'e')
+	// 每列都是全1
+	rows := []byte{0x11, 0, 0, 0, 0, 0, 0x01, 0x00, 0x02, 0x00, 5, 0x1f, 0x00}
+	rows = append(rows, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	rows = append(rows, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	_, events := readTestEvents(t, testTableMapEventWith(columnTypes, nil, metadata), testEventPacket(EventTypeWriteRowsEventv2, rows))
+	values := events[1].(RowsEventType).Rows[0].Value1
+	if len(values) != 5 {
+		t.Fatal("values=", values)
+	}
+	if val, ok := values[0].GetUint8(); !ok || val != math.MaxUint8 {
+		t.Error("tinyint unsigned=", values[0])
+	}
+	if val, ok := values[1].GetInt16(); !ok || val != -1 {
+		t.Error("smallint=", values[1])
+	}
+	if val, ok := values[2].GetUint32(); !ok || val != 1<<24-1 {
+		t.Error("mediumint unsigned=", values[2])
+	}
+	if val, ok := values[3].GetInt32(); !ok || val != -1 {
+		t.Error("int=", values[3])
+	}
+	if val, ok := values[4].GetUint64(); !ok || val != math.MaxUint64 {
+		t.Error("bigint unsigned=", values[4])
+	}
+
+	// 反过来，第2、4列UNSIGNED
+	metadata[2] = 0x50
+	_, events = readTestEvents(t, testTableMapEventWith(columnTypes, nil, metadata), testEventPacket(EventTypeWriteRowsEventv2, rows))
+	values = events[1].(RowsEventType).Rows[0].Value1
+	if val, ok := values[0].GetInt8(); !ok || val != -1 {
+		t.Error("tinyint=", values[0])
+	}
+	if val, ok := values[1].GetUint16(); !ok || val != math.MaxUint16 {
+		t.Error("smallint unsigned=", values[1])
+	}
+	if val, ok := values[2].GetInt32(); !ok || val != -1 {
+		t.Error("mediumint=", values[2])
+	}
+	if val, ok := values[3].GetUint32(); !ok || val != math.MaxUint32 {
+		t.Error("int unsigned=", values[3])
+	}
+	if val, ok := values[4].GetInt64(); !ok || val != -1 {
+		t.Error("bigint=", values[4])
+	}
+}
+
+// 只有符号元数据、没有列名和DDL的BIGINT UNSIGNED
+func TestUnsignedColumnValuesWithoutColumnName(t *testing.T) {
+	metadata := []byte{byte(TableMapMetadataSignedness), 1, 0x80}
+	rows := []byte{0x11, 0, 0, 0, 0, 0, 0x01, 0x00, 0x02, 0x00, 1, 0x01, 0x00}
+	rows = append(rows, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	_, events := readTestEvents(t, testTableMapEventWith([]ColumnType{ColumnTypeLonglong}, nil, metadata), testEventPacket(EventTypeWriteRowsEventv2, rows))
+	value := events[1].(RowsEventType).Rows[0].Value1[0]
+	if val, ok := value.GetUint64(); !ok || val != math.MaxUint64 {
+		t.Error("bigint unsigned=", value)
+	}
+	if _, ok := value.GetInt64(); ok {
+		t.Error("bigint unsigned read as int64")
+	}
+}